@@ -12,6 +12,7 @@ import (
 	"github.com/scryinfo/dp/dots/app/settings"
 	"github.com/scryinfo/dp/dots/app/websocket"
 	sdk2 "github.com/scryinfo/dp/dots/binary/sdk"
+	"github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
 	"github.com/scryinfo/scryg/sutils/ssignal"
 	"go.uber.org/zap"
 	"os"
@@ -42,22 +43,28 @@ func Init(l dot.Line) (err error) {
 	l.SConfig().UnmarshalKey("app", conf)
 	app2.GetGapp().ScryInfo = conf
 	//todo
-	app2.GetGapp().ChainWrapper, err = sdk2.Init(
+	app2.GetGapp().Sdk, err = sdk2.Init(
 		conf.Chain.Ethereum.EthNode,
 		conf.Chain.Contracts.ProtocolAddr,
 		conf.Chain.Contracts.TokenAddr,
 		conf.Services.Keystore,
 		conf.Services.Ipfs,
 		conf.Config.AppId,
+		conf.Config.CheckpointFile,
+		conf.Config.DeadLetterFile,
+		chainoperations.GasConfig(conf.Chain.Ethereum.Gas),
+		conf.Chain.Ethereum.ChainId,
 	)
 	if err != nil {
 		logger.Errorln("", zap.NamedError("", err))
+		return err
 	}
+	app2.GetGapp().ChainWrapper = app2.GetGapp().Sdk.ChainWrapper()
 	l.ToInjecter().ReplaceOrAddByType(app2.GetGapp().ChainWrapper)
 
 	logger.Infoln("ChainWrapper init finished. ")
 
-	app2.GetGapp().CurUser = sdkinterface2.CreateSDKWrapperImp(app2.GetGapp().ChainWrapper, app2.GetGapp().ScryInfo)
+	app2.GetGapp().CurUser = sdkinterface2.CreateSDKWrapperImp(app2.GetGapp().Sdk, app2.GetGapp().ScryInfo)
 
 	websocket.MessageHandlerInit()
 
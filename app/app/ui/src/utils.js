@@ -208,7 +208,7 @@ let presetFunc = {
     },
     onProofFilesExtensions: function (payload, _this) {
         dl_db.read(payload, function (dlInstance) {
-            utils.send({ Name:"extensions", Payload: {extensions: dlInstance.ProofDataExtensions}});
+            utils.send({ Name:"extensions", Payload: {publishId: payload, extensions: dlInstance.ProofDataExtensions}});
             utils.addCallbackFunc("extensions.callback", function (payload, _this) {});
             utils.addCallbackFunc("extensions.callback.error", function (payload, _this) {
                 _this.$alert(payload, "获取证明文件扩展名失败！", {
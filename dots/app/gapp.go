@@ -6,6 +6,7 @@ package app
 import (
 	sdkinterface2 "github.com/scryinfo/dp/dots/app/sdkinterface"
 	settings2 "github.com/scryinfo/dp/dots/app/settings"
+	sdk2 "github.com/scryinfo/dp/dots/binary/sdk"
 	"github.com/scryinfo/dp/dots/binary/sdk/scry"
 )
 
@@ -13,6 +14,7 @@ var app Gapp
 
 //todo 减少全局变量的个数
 type Gapp struct {
+	Sdk          *sdk2.Handle
 	ChainWrapper scry.ChainWrapper
 	Deployer     scry.Client
 	CurUser      sdkinterface2.SDKWrapper
@@ -4,27 +4,42 @@
 package sdkinterface
 
 import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/scryinfo/dp/dots/app/settings"
 	chainevents2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainevents"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"github.com/scryinfo/dp/dots/binary/sdk/scry"
 	"math/big"
 )
 
 // wrap sdk interface call.
 type SDKWrapper interface {
+	SetFromBlock(fromBlock uint64)
+	SubscribeSyncProgress(cb chainevents2.ProgressCallback) (cancel func())
+	SubscribeScanErrors(cb chainevents2.ErrorCallback) (cancel func())
+	QueryEvents(ctx context.Context, from, to uint64, names []string, filter events2.EventFilter) ([]events2.Event, error)
 	CreateUserWithLogin(password string) (string, error)
+	// SetAppId makes the current user transact as and get the events of another front-end
+	SetAppId(appId string) error
 	UserLogin(address string, password string) (bool, error)
-	TransferTokenFromDeployer(token *big.Int) error
+	TransferTokenFromDeployer(token *big.Int) (*scry.TxHandle, error)
+	GetTokenBalance() (*big.Int, error)
+	CurrentAddress() (common.Address, error)
 	SubscribeEvents(eventName []string, cb ...chainevents2.EventCallback) error
 	UnsubscribeEvents(eventName []string) error
-	PublishData(data *settings.PublishData) (string, error)
-	ApproveTransferToken(password string, quantity *big.Int) error
-	CreateTransaction(publishId string, password string, startVerify bool) error
-	Buy(txId string, password string) error
-	SubmitMetaDataIdEncWithBuyer(txId string, password, seller, buyer string, metaDataIDEncSeller []byte) error
-	CancelTransaction(txId, password string) error
+	PublishData(data *settings.PublishData) (string, *scry.TxHandle, error)
+	ApproveTransferToken(password string, quantity *big.Int) (*scry.TxHandle, error)
+	CreateTransaction(publishId string, password string, startVerify bool) (*scry.TxHandle, error)
+	Buy(txId string, password string) (*scry.TxHandle, error)
+	SubmitMetaDataIdEncWithBuyer(txId string, password, seller, buyer string, metaDataIDEncSeller []byte) (*scry.TxHandle, error)
+	CancelTransaction(txId, password string) (*scry.TxHandle, error)
 	DecryptAndGetMetaDataFromIPFS(password string, metaDataIdEncWithBuyer []byte, buyer, extension string) (string, error)
-	ConfirmDataTruth(txId string, password string, truth bool) error
-	RegisterAsVerifier(password string) error
-	Vote(password, txId string, judge bool, comment string) error
-	CreditToVerifiers(creditData *settings.CreditData) error
+	ConfirmDataTruth(txId string, password string, truth bool) (*scry.TxHandle, error)
+	RegisterAsVerifier(password string) (*scry.TxHandle, error)
+	Vote(password, txId string, judge bool, comment string) (*scry.TxHandle, error)
+	CreditToVerifiers(creditData *settings.CreditData) ([]*scry.TxHandle, error)
+	// SpeedUpTx and CancelTx replace a pending transaction of the current user, the handle of the first one follows them
+	SpeedUpTx(txHash string, password string) (*scry.TxHandle, error)
+	CancelTx(txHash string, password string) (*scry.TxHandle, error)
 }
@@ -4,12 +4,14 @@
 package sdkinterface
 
 import (
+	"context"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dp/dots/app/settings"
 	sdk2 "github.com/scryinfo/dp/dots/binary/sdk"
 	chainevents2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainevents"
 	chainoperations2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
 	"github.com/scryinfo/dp/dots/binary/sdk/scry"
 	accounts2 "github.com/scryinfo/dp/dots/binary/sdk/util/accounts"
 	ipfsaccess2 "github.com/scryinfo/dp/dots/binary/sdk/util/storage/ipfsaccess"
@@ -20,23 +22,46 @@ import (
 type sdkWrapperImp struct {
 	curUser scry.Client
 	dp      scry.Client
+	sdk     *sdk2.Handle
 	cw      scry.ChainWrapper
 	si      *settings.ScryInfo
 }
 
-func CreateSDKWrapperImp(cw scry.ChainWrapper, si *settings.ScryInfo) SDKWrapper {
+func CreateSDKWrapperImp(sdk *sdk2.Handle, si *settings.ScryInfo) SDKWrapper {
 	return &sdkWrapperImp{
-		cw: cw,
-		si: si,
+		sdk: sdk,
+		cw:  sdk.ChainWrapper(),
+		si:  si,
 	}
 }
 
-func SetFromBlock(fromBlock uint64) {
-	sdk2.StartScan(fromBlock)
+func (swi *sdkWrapperImp) SetFromBlock(fromBlock uint64) {
+	swi.sdk.StartScan(fromBlock)
+}
+
+func (swi *sdkWrapperImp) SubscribeSyncProgress(cb chainevents2.ProgressCallback) (cancel func()) {
+	return swi.sdk.Engine().SubscribeProgress(cb)
+}
+
+func (swi *sdkWrapperImp) SubscribeScanErrors(cb chainevents2.ErrorCallback) (cancel func()) {
+	return swi.sdk.Engine().SubscribeErrors(cb)
+}
+
+func (swi *sdkWrapperImp) QueryEvents(ctx context.Context, from, to uint64, names []string, filter events2.EventFilter) ([]events2.Event, error) {
+	return swi.sdk.QueryEvents(ctx, from, to, names, filter)
+}
+
+func (swi *sdkWrapperImp) SetAppId(appId string) error {
+	if swi.curUser == nil {
+		return errors.New("Current user is nil. ")
+	}
+	swi.curUser.SetAppId(appId)
+
+	return nil
 }
 
 func (swi *sdkWrapperImp) CreateUserWithLogin(password string) (string, error) {
-	client, err := scry.CreateScryClient(password, swi.cw)
+	client, err := scry.CreateScryClient(password, swi.cw, swi.sdk.Engine())
 	if err != nil {
 		return "", errors.Wrap(err, "Create new user failed. ")
 	}
@@ -48,7 +73,7 @@ func (swi *sdkWrapperImp) CreateUserWithLogin(password string) (string, error) {
 
 func (swi *sdkWrapperImp) UserLogin(address string, password string) (bool, error) {
 	var client scry.Client
-	if client = scry.NewScryClient(address, swi.cw); client == nil {
+	if client = scry.NewScryClient(address, swi.cw, swi.sdk.Engine()); client == nil {
 		return false, errors.New("Call NewScryClient failed. ")
 	}
 
@@ -63,19 +88,35 @@ func (swi *sdkWrapperImp) UserLogin(address string, password string) (bool, erro
 	return true, nil
 }
 
-func (swi *sdkWrapperImp) TransferTokenFromDeployer(token *big.Int) error {
+func (swi *sdkWrapperImp) GetTokenBalance() (*big.Int, error) {
+	if swi.curUser == nil {
+		return nil, errors.New("Current user is nil. ")
+	}
+
+	return swi.curUser.GetScryToken(common.HexToAddress(swi.curUser.Account().Address))
+}
+
+func (swi *sdkWrapperImp) CurrentAddress() (common.Address, error) {
+	if swi.curUser == nil {
+		return common.Address{}, errors.New("Current user is nil. ")
+	}
+
+	return common.HexToAddress(swi.curUser.Account().Address), nil
+}
+
+func (swi *sdkWrapperImp) TransferTokenFromDeployer(token *big.Int) (*scry.TxHandle, error) {
 	var err error
 	if swi.dp == nil {
 		swi.dp, err = swi.importAccount(swi.si.Chain.Contracts.DeployerKeyJson,
 			swi.si.Chain.Contracts.DeployerPassword,
 			swi.si.Chain.Contracts.DeployerPassword)
 		if err != nil {
-			return errors.Wrap(err, "Deployer init failed. ")
+			return nil, errors.Wrap(err, "Deployer init failed. ")
 		}
 	}
 
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
@@ -83,11 +124,12 @@ func (swi *sdkWrapperImp) TransferTokenFromDeployer(token *big.Int) error {
 		Password: swi.si.Chain.Contracts.DeployerPassword,
 		Value:    big.NewInt(0),
 		Pending:  false}
-	if err = swi.cw.TransferTokens(&txParam, common.HexToAddress(swi.curUser.Account().Address), token); err != nil {
-		return errors.Wrap(err, "Transfer token from deployer failed. ")
+	handle, err := swi.cw.TransferTokens(&txParam, common.HexToAddress(swi.curUser.Account().Address), token)
+	if err != nil {
+		return nil, errors.Wrap(err, "Transfer token from deployer failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 func (swi *sdkWrapperImp) importAccount(keyJson string, oldPassword string, newPassword string) (scry.Client, error) {
 	address, err := accounts2.GetAMInstance().ImportAccount([]byte(keyJson), oldPassword, newPassword)
@@ -95,7 +137,7 @@ func (swi *sdkWrapperImp) importAccount(keyJson string, oldPassword string, newP
 		return nil, errors.Wrap(err, "Import account failed. ")
 	}
 
-	return scry.NewScryClient(address, swi.cw), nil
+	return scry.NewScryClient(address, swi.cw, swi.sdk.Engine()), nil
 }
 
 func (swi *sdkWrapperImp) SubscribeEvents(eventName []string, cb ...chainevents2.EventCallback) error {
@@ -129,16 +171,17 @@ func (swi *sdkWrapperImp) UnsubscribeEvents(eventName []string) error {
 	return nil
 }
 
-func (swi *sdkWrapperImp) PublishData(data *settings.PublishData) (string, error) {
+func (swi *sdkWrapperImp) PublishData(data *settings.PublishData) (string, *scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return "", errors.New("Current user is nil. ")
+		return "", nil, errors.New("Current user is nil. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: data.Password,
 		Value:    big.NewInt(0),
-		Pending:  false}
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
 
 	return swi.cw.Publish(&txParam,
 		big.NewInt(int64(data.Price)),
@@ -149,106 +192,116 @@ func (swi *sdkWrapperImp) PublishData(data *settings.PublishData) (string, error
 		data.SupportVerify)
 }
 
-func (swi *sdkWrapperImp) ApproveTransferToken(password string, quantity *big.Int) error {
+func (swi *sdkWrapperImp) ApproveTransferToken(password string, quantity *big.Int) (*scry.TxHandle, error) {
 	protocolAddr := common.HexToAddress(swi.si.Chain.Contracts.ProtocolAddr)
 	return swi.approveTransfer(password, protocolAddr, quantity)
 }
 
-func (swi *sdkWrapperImp) approveTransfer(password string, protocolContractAddr common.Address, token *big.Int) error {
+func (swi *sdkWrapperImp) approveTransfer(password string, protocolContractAddr common.Address, token *big.Int) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.ApproveTransfer(&txParam, protocolContractAddr, token); err != nil {
-		return errors.Wrap(err, "Contract transfer token from buyer failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.ApproveTransfer(&txParam, protocolContractAddr, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "Contract transfer token from buyer failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) CreateTransaction(publishId string, password string, startVerify bool) error {
+func (swi *sdkWrapperImp) CreateTransaction(publishId string, password string, startVerify bool) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.PrepareToBuy(&txParam, publishId, startVerify); err != nil {
-		return errors.Wrap(err, "Transaction create failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.PrepareToBuy(&txParam, publishId, startVerify)
+	if err != nil {
+		return nil, errors.Wrap(err, "Transaction create failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) Buy(txId string, password string) error {
+func (swi *sdkWrapperImp) Buy(txId string, password string) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	tID, ok := new(big.Int).SetString(txId, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.BuyData(&txParam, tID); err != nil {
-		return errors.Wrap(err, "Buy data failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.BuyData(&txParam, tID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Buy data failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) SubmitMetaDataIdEncWithBuyer(txId string, password, seller, buyer string, metaDataIDEncSeller []byte) error {
+func (swi *sdkWrapperImp) SubmitMetaDataIdEncWithBuyer(txId string, password, seller, buyer string, metaDataIDEncSeller []byte) (*scry.TxHandle, error) {
 	metaDataIdEncWithBuyer, err := accounts2.GetAMInstance().ReEncrypt(metaDataIDEncSeller, seller, buyer, password)
 	if err != nil {
-		return errors.Wrap(err, "Re-encrypt meta data ID failed. ")
+		return nil, errors.Wrap(err, "Re-encrypt meta data ID failed. ")
 	}
 
 	tID, ok := new(big.Int).SetString(txId, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.SubmitMetaDataIdEncWithBuyer(&txParam, tID, metaDataIdEncWithBuyer); err != nil {
-		return errors.Wrap(err, "Submit encrypted ID with buyer failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.SubmitMetaDataIdEncWithBuyer(&txParam, tID, metaDataIdEncWithBuyer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Submit encrypted ID with buyer failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) CancelTransaction(txId, password string) error {
+func (swi *sdkWrapperImp) CancelTransaction(txId, password string) (*scry.TxHandle, error) {
 	tID, ok := new(big.Int).SetString(txId, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.CancelTransaction(&txParam, tID); err != nil {
-		return errors.Wrap(err, "Cancel transaction failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.CancelTransaction(&txParam, tID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cancel transaction failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
 func (swi *sdkWrapperImp) DecryptAndGetMetaDataFromIPFS(password string, metaDataIdEncWithBuyer []byte, buyer, extension string) (string, error) {
@@ -273,95 +326,155 @@ func (swi *sdkWrapperImp) DecryptAndGetMetaDataFromIPFS(password string, metaDat
 	return newFileName, nil
 }
 
-func (swi *sdkWrapperImp) ConfirmDataTruth(txId string, password string, truth bool) error {
+func (swi *sdkWrapperImp) ConfirmDataTruth(txId string, password string, truth bool) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	tID, ok := new(big.Int).SetString(txId, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.ConfirmDataTruth(&txParam, tID, truth); err != nil {
-		return errors.Wrap(err, "Confirm data truth failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.ConfirmDataTruth(&txParam, tID, truth)
+	if err != nil {
+		return nil, errors.Wrap(err, "Confirm data truth failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) RegisterAsVerifier(password string) error {
+func (swi *sdkWrapperImp) RegisterAsVerifier(password string) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.RegisterAsVerifier(&txParam); err != nil {
-		return errors.Wrap(err, "Register as verifier failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.RegisterAsVerifier(&txParam)
+	if err != nil {
+		return nil, errors.Wrap(err, "Register as verifier failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) Vote(password, txId string, judge bool, comment string) error {
+func (swi *sdkWrapperImp) Vote(password, txId string, judge bool, comment string) (*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	tID, ok := new(big.Int).SetString(txId, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: password,
 		Value:    big.NewInt(0),
-		Pending:  false}
-	if err := swi.cw.Vote(&txParam, tID, judge, comment); err != nil {
-		return errors.Wrap(err, "Vote failed. ")
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+	handle, err := swi.cw.Vote(&txParam, tID, judge, comment)
+	if err != nil {
+		return nil, errors.Wrap(err, "Vote failed. ")
 	}
 
-	return nil
+	return handle, nil
 }
 
-func (swi *sdkWrapperImp) CreditToVerifiers(creditData *settings.CreditData) error {
+func (swi *sdkWrapperImp) CreditToVerifiers(creditData *settings.CreditData) ([]*scry.TxHandle, error) {
 	if swi.curUser == nil {
-		return errors.New("Current user is nil. ")
+		return nil, errors.New("Current user is nil. ")
 	}
 
 	tID, ok := new(big.Int).SetString(creditData.SelectedTx.TransactionID, 10)
 	if !ok {
-		return errors.New("Set to *big.Int failed. ")
+		return nil, errors.New("Set to *big.Int failed. ")
 	}
 
 	txParam := chainoperations2.TransactParams{
 		From:     common.HexToAddress(swi.curUser.Account().Address),
 		Password: creditData.Password,
 		Value:    big.NewInt(0),
-		Pending:  false}
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
 
+	var handles []*scry.TxHandle
 	if creditData.Credit.Verifier1Revert {
 		credit := uint8(creditData.Credit.Verifier1Credit)
-		if err := swi.cw.CreditsToVerifier(&txParam, tID, 0, credit); err != nil {
-			return errors.Wrap(err, "Credit verifier1 failed. ")
+		handle, err := swi.cw.CreditsToVerifier(&txParam, tID, 0, credit)
+		if err != nil {
+			return handles, errors.Wrap(err, "Credit verifier1 failed. ")
 		}
+		handles = append(handles, handle)
 	}
 	if creditData.Credit.Verifier2Revert {
 		credit := uint8(creditData.Credit.Verifier2Credit)
-		if err := swi.cw.CreditsToVerifier(&txParam, tID, 1, credit); err != nil {
-			return errors.Wrap(err, "Credit verifier2 failed. ")
+		handle, err := swi.cw.CreditsToVerifier(&txParam, tID, 1, credit)
+		if err != nil {
+			return handles, errors.Wrap(err, "Credit verifier2 failed. ")
 		}
+		handles = append(handles, handle)
 	}
 
-	return nil
+	return handles, nil
+}
+
+func (swi *sdkWrapperImp) SpeedUpTx(txHash string, password string) (*scry.TxHandle, error) {
+	txParam, handle, err := swi.pendingTx(txHash, password)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := swi.cw.SpeedUp(txParam, handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "Speed up transaction failed. ")
+	}
+
+	return next, nil
+}
+
+func (swi *sdkWrapperImp) CancelTx(txHash string, password string) (*scry.TxHandle, error) {
+	txParam, handle, err := swi.pendingTx(txHash, password)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := swi.cw.Cancel(txParam, handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cancel pending transaction failed. ")
+	}
+
+	return next, nil
+}
+
+func (swi *sdkWrapperImp) pendingTx(txHash string, password string) (*chainoperations2.TransactParams, *scry.TxHandle, error) {
+	if swi.curUser == nil {
+		return nil, nil, errors.New("Current user is nil. ")
+	}
+
+	handle := swi.cw.TxByHash(common.HexToHash(txHash))
+	if handle == nil {
+		return nil, nil, errors.New("Unknown or mined transaction: " + txHash + " . ")
+	}
+
+	txParam := chainoperations2.TransactParams{
+		From:     common.HexToAddress(swi.curUser.Account().Address),
+		Password: password,
+		Value:    big.NewInt(0),
+		Pending:  false,
+		AppId:    swi.curUser.AppId()}
+
+	return &txParam, handle, nil
 }
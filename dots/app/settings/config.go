@@ -21,6 +21,20 @@ type Contracts struct {
 }
 type Ethereum struct {
 	EthNode string `yaml:"ethNode",json:"ethNode"`
+	Gas     Gas    `yaml:"gas",json:"gas"`
+	// ChainId overrides the chain id of the node, 0 reads it from the node
+	ChainId uint64 `yaml:"chainId",json:"chainId"`
+}
+
+// Gas prices are in wei, see chainoperations.GasConfig.
+type Gas struct {
+	Strategy    string  `yaml:"strategy",json:"strategy"`
+	Price       string  `yaml:"price",json:"price"`
+	Blocks      int     `yaml:"blocks",json:"blocks"`
+	Percentile  int     `yaml:"percentile",json:"percentile"`
+	Multiplier  float64 `yaml:"multiplier",json:"multiplier"`
+	PriceCap    string  `yaml:"priceCap",json:"priceCap"`
+	LimitMargin float64 `yaml:"limitMargin",json:"limitMargin"`
 }
 
 type Services struct {
@@ -33,4 +47,6 @@ type Config struct {
 	UIResourcesDir string `yaml:"uiResourcesDir",json:"uiResourcesDir"`
 	AppId          string `yaml:"appId",json:"appId"`
 	IPFSOutDir     string `yaml:"ipfsOutDir",json:"ipfsOutDir"`
+	CheckpointFile string `yaml:"checkpointFile",json:"checkpointFile"`
+	DeadLetterFile string `yaml:"deadLetterFile",json:"deadLetterFile"`
 }
@@ -20,6 +20,8 @@ type PresetFunc = func(*MessageIn) (interface{}, error)
 type AccInfo struct {
 	Account  string `json:"account"`
 	Password string `json:"password"`
+	// AppId of the front-end, empty for the configured appId
+	AppId string `json:"appId"`
 }
 
 type SDKInitData struct {
@@ -49,6 +51,48 @@ type OnPublish struct {
 	PublishID           string
 	SupportVerify       bool
 	Block               uint64
+	Time                uint64
+}
+
+type OnBalanceChange struct {
+	From     string
+	To       string
+	Value    string
+	Incoming bool
+	Balance  string
+	Block    uint64
+}
+
+type OnSyncProgress struct {
+	Current         uint64
+	Head            uint64
+	Lag             uint64
+	EventsPerSecond float64
+}
+
+type OnSyncError struct {
+	Kind        string
+	Block       uint64
+	Consecutive int
+	Error       string
+}
+
+// OnTxStatus is sent as "submitted" in the callback of the message Op,
+// and as "mined", "cancelled" or "failed" in a "tx.status" message once the transaction is confirmed.
+// MinedTxHash is the speed up or cancel mined instead of TxHash.
+type OnTxStatus struct {
+	Op          string
+	TxHash      string
+	MinedTxHash string
+	Status      string
+	Block       uint64
+	GasUsed     uint64
+	Error       string
+}
+
+type ReplaceTxData struct {
+	Password string `json:"password"`
+	TxHash   string `json:"txHash"`
 }
 
 type BuyData struct {
@@ -83,7 +127,9 @@ type OnTransactionCreate struct {
 	Block          uint64
 }
 
+// Prepared answers "onProofFilesExtensions", PublishID is the payload of the request.
 type Prepared struct {
+	PublishID  string   `json:"publishId"`
 	Extensions []string `json:"extensions"`
 }
 
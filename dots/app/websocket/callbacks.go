@@ -6,31 +6,38 @@ package websocket
 import (
 	"encoding/json"
 	"github.com/btcsuite/btcutil/base58"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dot/dot"
 	app2 "github.com/scryinfo/dp/dots/app"
 	"github.com/scryinfo/dp/dots/app/settings"
+	chainevents2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainevents"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
 	ipfsaccess2 "github.com/scryinfo/dp/dots/binary/sdk/util/storage/ipfsaccess"
 	"go.uber.org/zap"
 	"io/ioutil"
-	"math/big"
 	"os"
 	"strconv"
 )
 
 func onPublish(event events2.Event) bool {
+	dp, ok := event.Typed.(*events2.DataPublish)
+	if !ok {
+		return unexpectedEvent("onPublish", event)
+	}
+
 	var op settings.OnPublish
 	{
 		var err error
-		if op, err = getPubDataDetails(event.Data.Get("despDataId").(string)); err != nil {
+		if op, err = getPubDataDetails(dp.DespDataId); err != nil {
+			// false gets the event retried, the details are on IPFS and the node may be back later
 			dot.Logger().Errorln("", zap.NamedError("onPublish: get publish data details failed. ", err))
+			return false
 		}
 		op.Block = event.BlockNumber
-		op.Price = event.Data.Get("price").(*big.Int).String()
-		op.PublishID = event.Data.Get("publishId").(string)
-		op.SupportVerify = event.Data.Get("supportVerify").(bool)
+		op.Time = event.Timestamp
+		op.Price = dp.Price.String()
+		op.PublishID = dp.PublishId
+		op.SupportVerify = dp.SupportVerify
 	}
 
 	if err := sendMessage("onPublish", op); err != nil {
@@ -40,10 +47,41 @@ func onPublish(event events2.Event) bool {
 	return true
 }
 
+func onSyncProgress(progress chainevents2.SyncProgress) {
+	sp := settings.OnSyncProgress{
+		Current:         progress.Current,
+		Head:            progress.Head,
+		Lag:             progress.Lag,
+		EventsPerSecond: progress.EventsPerSecond,
+	}
+
+	if err := sendMessage("sync.progress", sp); err != nil {
+		dot.Logger().Debugln("", zap.NamedError("sync.progress"+EventSendFailed, err))
+	}
+}
+
+// onSyncError only reports failures the scanner could not recover from by retrying.
+func onSyncError(failure chainevents2.ScanFailure) {
+	if !failure.Persistent {
+		return
+	}
+	se := settings.OnSyncError{
+		Kind:        failure.Kind.String(),
+		Block:       failure.Block,
+		Consecutive: failure.Consecutive,
+		Error:       failure.Err.Error(),
+	}
+
+	if err := sendMessage("sync.error", se); err != nil {
+		dot.Logger().Errorln("", zap.NamedError("sync.error"+EventSendFailed, err))
+	}
+}
+
 func getPubDataDetails(ipfsID string) (detailsData settings.OnPublish, err error) {
 	defer func() {
 		if er := recover(); er != nil {
 			dot.Logger().Errorln("", zap.Any("onPublish.callback: get publish data details failed. ", er))
+			err = errors.Errorf("get publish data details panicked: %v", er)
 		}
 	}()
 
@@ -83,21 +121,58 @@ func onApprove(_ events2.Event) bool {
 	return true
 }
 
-func onVerifiersChosen(event events2.Event) bool {
-	var ovc settings.OnVerifiersChosen
+// onTransfer tells the UI about tokens the current user received or sent.
+func onTransfer(event events2.Event) bool {
+	tr, ok := event.Typed.(*events2.Transfer)
+	if !ok {
+		return unexpectedEvent("onTransfer", event)
+	}
+
+	var obc settings.OnBalanceChange
 	{
-		ovc.PublishID = event.Data.Get("publishId").(string)
-		if err := sendMessage("onProofFilesExtensions", ovc.PublishID); err != nil {
-			dot.Logger().Errorln("", zap.NamedError("onProofFilesExtensions"+EventSendFailed, err))
+		user, err := app2.GetGapp().CurUser.CurrentAddress()
+		if err != nil {
+			dot.Logger().Errorln("", zap.NamedError("onTransfer: no current user. ", err))
+			return true
+		}
+		obc.From = tr.From.String()
+		obc.To = tr.To.String()
+		obc.Value = tr.Value.String()
+		obc.Incoming = tr.To == user
+		obc.Block = event.BlockNumber
+		if balance, err := app2.GetGapp().CurUser.GetTokenBalance(); err != nil {
+			dot.Logger().Errorln("", zap.NamedError("onTransfer: get token balance failed. ", err))
+		} else {
+			obc.Balance = balance.String()
 		}
+	}
 
+	if err := sendMessage("balance.change", obc); err != nil {
+		dot.Logger().Errorln("", zap.NamedError("balance.change"+EventSendFailed, err))
+	}
+
+	return true
+}
+
+func onVerifiersChosen(event events2.Event) bool {
+	vc, ok := event.Typed.(*events2.VerifiersChosen)
+	if !ok {
+		return unexpectedEvent("onVerifiersChosen", event)
+	}
+
+	var ovc settings.OnVerifiersChosen
+	{
+		ovc.PublishID = vc.PublishId
 		ovc.Block = event.BlockNumber
-		ovc.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		ovc.TxState = setTxState(event.Data.Get("state").(uint8))
+		ovc.TransactionID = vc.TransactionId.String()
+		ovc.TxState = setTxState(vc.State)
 
-		extensions := <-extChan
-		var err error
-		if ovc.ProofFileNames, err = getAndRenameProofFiles(event.Data.Get("proofIds").([][32]uint8), extensions); err != nil {
+		extensions, err := requestExtensions(ovc.PublishID)
+		if err != nil {
+			dot.Logger().Errorln("", zap.NamedError("Node - onVC.callback: get proof files extensions failed. ", err))
+			return false
+		}
+		if ovc.ProofFileNames, err = getAndRenameProofFiles(vc.ProofIds, extensions); err != nil {
 			dot.Logger().Errorln("", zap.NamedError("Node - onVC.callback: get and rename proof files failed. ", err))
 		}
 	}
@@ -110,22 +185,26 @@ func onVerifiersChosen(event events2.Event) bool {
 }
 
 func onTransactionCreate(event events2.Event) bool {
+	tc, ok := event.Typed.(*events2.TransactionCreate)
+	if !ok || len(tc.Users) == 0 {
+		return unexpectedEvent("onTransactionCreate", event)
+	}
+
 	var otc settings.OnTransactionCreate
 	{
-		otc.PublishID = event.Data.Get("publishId").(string)
-		if err := sendMessage("onProofFilesExtensions", otc.PublishID); err != nil {
-			dot.Logger().Errorln("", zap.NamedError("onProofFilesExtensions"+EventSendFailed, err))
-		}
-
+		otc.PublishID = tc.PublishId
 		otc.Block = event.BlockNumber
-		otc.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		otc.Buyer = event.Data.Get("users").([]common.Address)[0].String()
-		otc.StartVerify = event.Data.Get("needVerify").(bool)
-		otc.TxState = setTxState(event.Data.Get("state").(uint8))
-
-		extensions := <-extChan
-		var err error
-		if otc.ProofFileNames, err = getAndRenameProofFiles(event.Data.Get("proofIds").([][32]uint8), extensions); err != nil {
+		otc.TransactionID = tc.TransactionId.String()
+		otc.Buyer = tc.Users[0].String()
+		otc.StartVerify = tc.NeedVerify
+		otc.TxState = setTxState(tc.State)
+
+		extensions, err := requestExtensions(otc.PublishID)
+		if err != nil {
+			dot.Logger().Errorln("", zap.NamedError("Node - onTC.callback: get proof files extensions failed. ", err))
+			return false
+		}
+		if otc.ProofFileNames, err = getAndRenameProofFiles(tc.ProofIds, extensions); err != nil {
 			dot.Logger().Errorln("", zap.NamedError("Node - onTC.callback: get and rename proof files failed. ", err))
 		}
 	}
@@ -178,17 +257,22 @@ func ipfsBytes32ToHash(ipfsb [32]byte) string {
 }
 
 func onPurchase(event events2.Event) bool {
+	buy, ok := event.Typed.(*events2.Buy)
+	if !ok {
+		return unexpectedEvent("onPurchase", event)
+	}
+
 	var op settings.OnPurchase
 	{
 		op.Block = event.BlockNumber
-		op.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		op.MetaDataIdEncWithSeller = event.Data.Get("metaDataIdEncSeller").([]byte)
-		op.PublishID = event.Data.Get("publishId").(string)
-		op.UserIndex = strconv.Itoa(int(event.Data.Get("index").(uint8)))
-		op.TxState = setTxState(event.Data.Get("state").(uint8))
+		op.TransactionID = buy.TransactionId.String()
+		op.MetaDataIdEncWithSeller = buy.MetaDataIdEncSeller
+		op.PublishID = buy.PublishId
+		op.UserIndex = strconv.Itoa(int(buy.Index))
+		op.TxState = setTxState(buy.State)
 
 		// temp
-		op.Buyer = event.Data.Get("buyer").(common.Address).String()
+		op.Buyer = buy.Buyer.String()
 	}
 
 	if err := sendMessage("onPurchase", op); err != nil {
@@ -199,13 +283,18 @@ func onPurchase(event events2.Event) bool {
 }
 
 func onReadyForDownload(event events2.Event) bool {
+	rfd, ok := event.Typed.(*events2.ReadyForDownload)
+	if !ok {
+		return unexpectedEvent("onReadyForDownload", event)
+	}
+
 	var orfd settings.OnReadyForDownload
 	{
 		orfd.Block = event.BlockNumber
-		orfd.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		orfd.MetaDataIdEncWithBuyer = event.Data.Get("metaDataIdEncBuyer").([]byte)
-		orfd.UserIndex = strconv.Itoa(int(event.Data.Get("index").(uint8)))
-		orfd.TxState = setTxState(event.Data.Get("state").(uint8))
+		orfd.TransactionID = rfd.TransactionId.String()
+		orfd.MetaDataIdEncWithBuyer = rfd.MetaDataIdEncBuyer
+		orfd.UserIndex = strconv.Itoa(int(rfd.Index))
+		orfd.TxState = setTxState(rfd.State)
 	}
 
 	if err := sendMessage("onReadyForDownload", orfd); err != nil {
@@ -216,12 +305,17 @@ func onReadyForDownload(event events2.Event) bool {
 }
 
 func onClose(event events2.Event) bool {
+	tc, ok := event.Typed.(*events2.TransactionClose)
+	if !ok {
+		return unexpectedEvent("onClose", event)
+	}
+
 	var oc settings.OnClose
 	{
 		oc.Block = event.BlockNumber
-		oc.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		oc.UserIndex = strconv.Itoa(int(event.Data.Get("index").(uint8)))
-		oc.TxState = setTxState(event.Data.Get("state").(uint8))
+		oc.TransactionID = tc.TransactionId.String()
+		oc.UserIndex = strconv.Itoa(int(tc.Index))
+		oc.TxState = setTxState(tc.State)
 	}
 
 	if err := sendMessage("onClose", oc); err != nil {
@@ -245,16 +339,18 @@ func onRegisterAsVerifier(event events2.Event) bool {
 }
 
 func onVote(event events2.Event) bool {
+	vote, ok := event.Typed.(*events2.Vote)
+	if !ok {
+		return unexpectedEvent("onVote", event)
+	}
+
 	var ov settings.OnVote
 	{
 		ov.Block = event.BlockNumber
-		ov.VerifierIndex = strconv.Itoa(int(event.Data.Get("index").(uint8)))
-		ov.TransactionID = event.Data.Get("transactionId").(*big.Int).String()
-		ov.TxState = setTxState(event.Data.Get("state").(uint8))
-
-		judge := event.Data.Get("judge").(bool)
-		comment := event.Data.Get("comments").(string)
-		ov.VerifierResponse = setJudge(judge) + ", " + comment
+		ov.VerifierIndex = strconv.Itoa(int(vote.Index))
+		ov.TransactionID = vote.TransactionId.String()
+		ov.TxState = setTxState(vote.State)
+		ov.VerifierResponse = setJudge(vote.Judge) + ", " + vote.Comments
 	}
 
 	if err := sendMessage("onVote", ov); err != nil {
@@ -277,6 +373,11 @@ func onVerifierDisable(event events2.Event) bool {
 	return true
 }
 
+func unexpectedEvent(name string, event events2.Event) bool {
+	dot.Logger().Errorln(name + ": unexpected event data, event: " + event.String())
+	return false
+}
+
 func setTxState(state byte) (str string) {
 	switch state {
 	case 1:
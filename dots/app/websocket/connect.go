@@ -110,6 +110,10 @@ func sendMessage(name string, payload interface{}) error {
 		Payload: payload,
 	}
 
+	if connParams == nil {
+		return errors.New("No websocket connection. ")
+	}
+
 	b, err := json.Marshal(mo)
 	if err != nil {
 		return errors.Wrap(err, "Json marshal failed. ")
@@ -0,0 +1,76 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package websocket
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// extensionsTimeout is how long a callback waits for the UI to answer the proof file extensions
+const extensionsTimeout = time.Minute
+
+// extWaiters are the callbacks waiting for the proof file extensions, by publish id.
+// Callbacks run in parallel, the publish id tells which of them an answer of the UI belongs to.
+var (
+	extWaiters = make(map[string][]chan []string)
+	extMu      sync.Mutex
+)
+
+// requestExtensions asks the UI for the proof file extensions of the publish and waits for the answer.
+func requestExtensions(publishID string) ([]string, error) {
+	ch := make(chan []string, 1)
+	extMu.Lock()
+	extWaiters[publishID] = append(extWaiters[publishID], ch)
+	extMu.Unlock()
+	defer dropExtWaiter(publishID, ch)
+
+	if err := sendMessage("onProofFilesExtensions", publishID); err != nil {
+		return nil, errors.Wrap(err, "onProofFilesExtensions"+EventSendFailed)
+	}
+
+	select {
+	case extensions := <-ch:
+		return extensions, nil
+	case <-time.After(extensionsTimeout):
+		return nil, errors.New("No proof file extensions of publish " + publishID + " from the UI. ")
+	}
+}
+
+// answerExtensions hands the extensions to the earliest callback waiting for the publish, false if none waits.
+func answerExtensions(publishID string, extensions []string) bool {
+	extMu.Lock()
+	defer extMu.Unlock()
+
+	waiters := extWaiters[publishID]
+	if len(waiters) == 0 {
+		return false
+	}
+	waiters[0] <- extensions
+	setExtWaiters(publishID, waiters[1:])
+	return true
+}
+
+func dropExtWaiter(publishID string, ch chan []string) {
+	extMu.Lock()
+	defer extMu.Unlock()
+
+	waiters := extWaiters[publishID]
+	for i, w := range waiters {
+		if w == ch {
+			setExtWaiters(publishID, append(append([]chan []string{}, waiters[:i]...), waiters[i+1:]...))
+			return
+		}
+	}
+}
+
+// setExtWaiters is called with extMu held.
+func setExtWaiters(publishID string, waiters []chan []string) {
+	if len(waiters) == 0 {
+		delete(extWaiters, publishID)
+		return
+	}
+	extWaiters[publishID] = waiters
+}
@@ -5,9 +5,10 @@ package websocket
 
 import (
 	"encoding/json"
+	"github.com/pkg/errors"
 	app2 "github.com/scryinfo/dp/dots/app"
-	"github.com/scryinfo/dp/dots/app/sdkinterface"
 	"github.com/scryinfo/dp/dots/app/settings"
+	"github.com/scryinfo/dp/dots/binary/sdk/scry"
 	"math/big"
 )
 
@@ -18,9 +19,10 @@ const (
 )
 
 var (
-	extChan   = make(chan []string, 3)
+	stopSync  func()
+	stopErr   func()
 	eventName = []string{"DataPublish", "Approval", "VerifiersChosen", "TransactionCreate", "Buy", "ReadyForDownload", "TransactionClose",
-		"RegisterVerifier", "Vote", "VerifierDisable"}
+		"RegisterVerifier", "Vote", "VerifierDisable", "Transfer"}
 )
 
 func MessageHandlerInit() {
@@ -39,6 +41,8 @@ func MessageHandlerInit() {
 	addCallbackFunc("register", register)
 	addCallbackFunc("verify", verify)
 	addCallbackFunc("credit", credit)
+	addCallbackFunc("tx.speedup", speedUpTx)
+	addCallbackFunc("tx.cancel", cancelTx)
 }
 
 func loginVerify(mi *settings.MessageIn) (payload interface{}, err error) {
@@ -49,6 +53,9 @@ func loginVerify(mi *settings.MessageIn) (payload interface{}, err error) {
 	if payload, err = app2.GetGapp().CurUser.UserLogin(ai.Account, ai.Password); !(payload.(bool)) {
 		return
 	}
+	if ai.AppId != "" {
+		err = app2.GetGapp().CurUser.SetAppId(ai.AppId)
+	}
 
 	return
 }
@@ -61,6 +68,9 @@ func createNewAccount(mi *settings.MessageIn) (payload interface{}, err error) {
 	if payload, err = app2.GetGapp().CurUser.CreateUserWithLogin(pwd.Password); err != nil {
 		return
 	}
+	if pwd.AppId != "" {
+		err = app2.GetGapp().CurUser.SetAppId(pwd.AppId)
+	}
 
 	return
 }
@@ -71,12 +81,16 @@ func blockSet(mi *settings.MessageIn) (payload interface{}, err error) {
 		return
 	}
 	if err = app2.GetGapp().CurUser.SubscribeEvents(eventName, onPublish, onApprove, onVerifiersChosen, onTransactionCreate, onPurchase, onReadyForDownload,
-		onClose, onRegisterAsVerifier, onVote, onVerifierDisable); err != nil {
+		onClose, onRegisterAsVerifier, onVote, onVerifierDisable, onTransfer); err != nil {
 		return
 	}
-	sdkinterface.SetFromBlock(uint64(sid.FromBlock))
+	if stopSync == nil {
+		stopSync = app2.GetGapp().CurUser.SubscribeSyncProgress(onSyncProgress)
+		stopErr = app2.GetGapp().CurUser.SubscribeScanErrors(onSyncError)
+	}
+	app2.GetGapp().CurUser.SetFromBlock(uint64(sid.FromBlock))
 	// when an user login success, he will get 1,000,000 tokens for test. in 'block.set' case.
-	if err = app2.GetGapp().CurUser.TransferTokenFromDeployer(big.NewInt(1000000)); err != nil { // for test
+	if _, err = app2.GetGapp().CurUser.TransferTokenFromDeployer(big.NewInt(1000000)); err != nil { // for test
 		return
 	}
 	payload = true
@@ -85,6 +99,11 @@ func blockSet(mi *settings.MessageIn) (payload interface{}, err error) {
 }
 
 func logout(_ *settings.MessageIn) (payload interface{}, err error) {
+	if stopSync != nil {
+		stopSync()
+		stopErr()
+		stopSync, stopErr = nil, nil
+	}
 	if err = app2.GetGapp().CurUser.UnsubscribeEvents(eventName); err != nil {
 		return
 	}
@@ -98,9 +117,12 @@ func publish(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &pd); err != nil {
 		return
 	}
-	if payload, err = app2.GetGapp().CurUser.PublishData(&pd); err != nil {
+	var handle *scry.TxHandle
+	if payload, handle, err = app2.GetGapp().CurUser.PublishData(&pd); err != nil {
 		return
 	}
+	// the callback keeps the publish id, the status follows by "tx.status"
+	watchTxs(mi.Name, handle)
 
 	return
 }
@@ -115,14 +137,16 @@ func buy(mi *settings.MessageIn) (payload interface{}, err error) {
 	if bd.StartVerify {
 		fee += int64(verifierNum * verifierBonus)
 	}
-	if err = app2.GetGapp().CurUser.ApproveTransferToken(bd.Password, big.NewInt(fee)); err != nil {
+	approve, err := app2.GetGapp().CurUser.ApproveTransferToken(bd.Password, big.NewInt(fee))
+	if err != nil {
 		return
 	}
 
-	if err = app2.GetGapp().CurUser.CreateTransaction(bd.SelectedData.PublishID, bd.Password, bd.StartVerify); err != nil {
+	create, err := app2.GetGapp().CurUser.CreateTransaction(bd.SelectedData.PublishID, bd.Password, bd.StartVerify)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, approve, create)
 
 	return
 }
@@ -132,7 +156,10 @@ func extensions(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &p); err != nil {
 		return
 	}
-	extChan <- p.Extensions
+	if !answerExtensions(p.PublishID, p.Extensions) {
+		err = errors.New("No callback waits for the extensions of publish " + p.PublishID + " . ")
+		return
+	}
 	payload = true
 
 	return
@@ -143,10 +170,11 @@ func purchase(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &pd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.Buy(pd.SelectedTx.TransactionID, pd.Password); err != nil {
+	handle, err := app2.GetGapp().CurUser.Buy(pd.SelectedTx.TransactionID, pd.Password)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handle)
 
 	return
 }
@@ -156,11 +184,12 @@ func reEncrypt(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &re); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.SubmitMetaDataIdEncWithBuyer(re.SelectedTx.TransactionID, re.Password, re.SelectedTx.Seller,
-		re.SelectedTx.Buyer, re.SelectedTx.MetaDataIDEncWithSeller); err != nil {
+	handle, err := app2.GetGapp().CurUser.SubmitMetaDataIdEncWithBuyer(re.SelectedTx.TransactionID, re.Password, re.SelectedTx.Seller,
+		re.SelectedTx.Buyer, re.SelectedTx.MetaDataIDEncWithSeller)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handle)
 
 	return
 }
@@ -170,10 +199,11 @@ func cancel(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &pd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.CancelTransaction(pd.SelectedTx.TransactionID, pd.Password); err != nil {
+	handle, err := app2.GetGapp().CurUser.CancelTransaction(pd.SelectedTx.TransactionID, pd.Password)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handle)
 
 	return
 }
@@ -196,10 +226,11 @@ func confirm(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &cd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.ConfirmDataTruth(cd.SelectedTx.TransactionID, cd.Password, cd.Truth); err != nil {
+	handle, err := app2.GetGapp().CurUser.ConfirmDataTruth(cd.SelectedTx.TransactionID, cd.Password, cd.Truth)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handle)
 
 	return
 }
@@ -209,13 +240,15 @@ func register(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &rvd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.ApproveTransferToken(rvd.Password, big.NewInt(registerAsVerifierCost)); err != nil {
+	approve, err := app2.GetGapp().CurUser.ApproveTransferToken(rvd.Password, big.NewInt(registerAsVerifierCost))
+	if err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.RegisterAsVerifier(rvd.Password); err != nil {
+	handle, err := app2.GetGapp().CurUser.RegisterAsVerifier(rvd.Password)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, approve, handle)
 
 	return
 }
@@ -225,10 +258,11 @@ func verify(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &vd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.Vote(vd.Password, vd.TransactionID, vd.Verify.Suggestion, vd.Verify.Comment); err != nil {
+	handle, err := app2.GetGapp().CurUser.Vote(vd.Password, vd.TransactionID, vd.Verify.Suggestion, vd.Verify.Comment)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handle)
 
 	return
 }
@@ -238,10 +272,39 @@ func credit(mi *settings.MessageIn) (payload interface{}, err error) {
 	if err = json.Unmarshal(mi.Payload, &cd); err != nil {
 		return
 	}
-	if err = app2.GetGapp().CurUser.CreditToVerifiers(&cd); err != nil {
+	handles, err := app2.GetGapp().CurUser.CreditToVerifiers(&cd)
+	if err != nil {
 		return
 	}
-	payload = true
+	payload = watchTxs(mi.Name, handles...)
+
+	return
+}
+
+func speedUpTx(mi *settings.MessageIn) (payload interface{}, err error) {
+	var rd settings.ReplaceTxData
+	if err = json.Unmarshal(mi.Payload, &rd); err != nil {
+		return
+	}
+	handle, err := app2.GetGapp().CurUser.SpeedUpTx(rd.TxHash, rd.Password)
+	if err != nil {
+		return
+	}
+	payload = replacedTx(mi.Name, handle)
+
+	return
+}
+
+func cancelTx(mi *settings.MessageIn) (payload interface{}, err error) {
+	var rd settings.ReplaceTxData
+	if err = json.Unmarshal(mi.Payload, &rd); err != nil {
+		return
+	}
+	handle, err := app2.GetGapp().CurUser.CancelTx(rd.TxHash, rd.Password)
+	if err != nil {
+		return
+	}
+	payload = replacedTx(mi.Name, handle)
 
 	return
 }
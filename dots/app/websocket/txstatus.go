@@ -0,0 +1,68 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package websocket
+
+import (
+	"context"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dp/dots/app/settings"
+	"github.com/scryinfo/dp/dots/binary/sdk/scry"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	txSubmitted = "submitted"
+	txMined     = "mined"
+	txFailed    = "failed"
+	txCancelled = "cancelled"
+
+	txConfirmations = 1
+	txWaitTimeout   = 30 * time.Minute
+)
+
+// watchTxs returns the "submitted" status of the transactions sent for op,
+// and reports each of them again by "tx.status" once it is mined or failed.
+func watchTxs(op string, handles ...*scry.TxHandle) []settings.OnTxStatus {
+	var submitted []settings.OnTxStatus
+	for _, handle := range handles {
+		if handle == nil {
+			continue
+		}
+		submitted = append(submitted, settings.OnTxStatus{Op: op, TxHash: handle.Hash().Hex(), Status: txSubmitted})
+		go waitTx(op, handle)
+	}
+
+	return submitted
+}
+
+// replacedTx returns the "submitted" status of a speed up or cancel,
+// the waiter of the replaced transaction reports the one mined of them.
+func replacedTx(op string, handle *scry.TxHandle) []settings.OnTxStatus {
+	return []settings.OnTxStatus{{Op: op, TxHash: handle.Hash().Hex(), Status: txSubmitted}}
+}
+
+func waitTx(op string, handle *scry.TxHandle) {
+	ctx, cancel := context.WithTimeout(context.Background(), txWaitTimeout)
+	defer cancel()
+
+	ts := settings.OnTxStatus{Op: op, TxHash: handle.Hash().Hex(), Status: txMined}
+	receipt, err := handle.Wait(ctx, txConfirmations)
+	if receipt != nil {
+		ts.Block, ts.GasUsed = receipt.BlockNumber, receipt.GasUsed
+		if receipt.TxHash != handle.Hash() {
+			ts.MinedTxHash = receipt.TxHash.Hex()
+		}
+	}
+	switch {
+	case err == scry.ErrTxCancelled:
+		ts.Status = txCancelled
+	case err != nil:
+		ts.Status, ts.Error = txFailed, err.Error()
+	}
+
+	if err := sendMessage("tx.status", ts); err != nil {
+		dot.Logger().Errorln("", zap.NamedError("tx.status"+EventSendFailed, err))
+	}
+}
@@ -4,38 +4,206 @@
 package chainevents
 
 import (
+	"context"
 	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/scryinfo/dot/dot"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	redo2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/redo"
+	"sync"
+	"time"
 )
 
 var (
-	maxChannelEventNum = 10000
-	externalEventRepo  = NewEventRepository()
-	dataChannel        = make(chan events2.Event, maxChannelEventNum)
-	errorChannel       = make(chan error, 1)
+	maxChannelEventNum    = 10000
+	maxChannelProgressNum = 100
+	maxChannelErrorNum    = 100
+	// backfillWorkers is the number of concurrent log queries while catching up
+	backfillWorkers = 4
 )
 
-func StartEventProcessing(
+// EventEngine owns the scanner, the subscriptions and the channels between them,
+// create one per ethclient and contract set.
+type EventEngine struct {
+	conn         *ethclient.Client
+	contracts    []ContractInfo
+	appId        string
+	checkpoint   events2.CheckpointStore
+	push         bool
+	interval     time.Duration
+	workers      int
+	builder      *events2.Builder
+	recipet      *redo2.Recipet
+	repo         *EventRepository
+	dataChannel  chan events2.Event
+	errorChannel chan error
+	quit         chan struct{}
+	mu           sync.Mutex
+
+	dispatchConfig DispatchConfig
+	dispatcher     *dispatcher
+	deadLetters    DeadLetterStore
+
+	progressChannel   chan events2.Progress
+	progress          SyncProgress
+	progressCallbacks map[int]ProgressCallback
+	progressSeq       int
+	progressMu        sync.Mutex
+
+	errorCallbacks map[int]ErrorCallback
+	errorSeq       int
+	failures       int
+	errorMu        sync.Mutex
+}
+
+// NewEventEngine dispatches the events of appId to plain subscriptions and filters without Apps.
+func NewEventEngine(
 	conn *ethclient.Client,
 	contracts []ContractInfo,
-) {
+	checkpoint events2.CheckpointStore,
+	push bool,
+	appId string,
+) *EventEngine {
+	e := &EventEngine{
+		conn:         conn,
+		contracts:    contracts,
+		appId:        appId,
+		checkpoint:   checkpoint,
+		push:         push,
+		interval:     60,
+		workers:      backfillWorkers,
+		repo:         NewEventRepository(),
+		errorChannel: make(chan error, maxChannelErrorNum),
+
+		progressChannel:   make(chan events2.Progress, maxChannelProgressNum),
+		progressCallbacks: make(map[int]ProgressCallback),
+		errorCallbacks:    make(map[int]ErrorCallback),
+	}
+	e.repo.removed = e.forgetCallbacks
+	return e
+}
+
+func (e *EventEngine) Start() error {
 	dot.Logger().Infoln("start event processing...")
 
-	go ExecuteEvents(dataChannel, externalEventRepo)
-	go ListenEvent(conn, contracts, 0, 60, dataChannel, errorChannel)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.quit != nil {
+		return errors.New("event engine is already started")
+	}
+
+	// events left by an earlier scanner must not be acknowledged to the new one
+	e.dataChannel = make(chan events2.Event, maxChannelEventNum)
+	if err := e.listen(); err != nil {
+		return err
+	}
+	e.quit = make(chan struct{})
+	e.dispatcher = newDispatcher(e.dispatchConfig, e.deadLetters)
+	e.dispatcher.session = e.repo.session
+	go e.executeEvents(e.dataChannel, e.dispatcher, e.builder, e.quit)
+	go e.trackProgress(e.progressChannel, e.quit)
+	go e.trackErrors(e.errorChannel, e.quit)
 
 	dot.Logger().Infoln("finished event processing.")
+	return nil
+}
+
+func (e *EventEngine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.quit == nil {
+		return
+	}
+
+	e.recipet.Stop()
+	e.builder.Close()
+	close(e.quit)
+	e.quit = nil
+}
+
+// AppId is the app whose events plain subscriptions and filters without Apps get.
+func (e *EventEngine) AppId() string {
+	return e.appId
+}
+
+// SetDispatchConfig takes effect on the next Start.
+func (e *EventEngine) SetDispatchConfig(config DispatchConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dispatchConfig = config
+}
+
+// SetBackfillWorkers limits the concurrent log queries while the scanner catches up,
+// 1 scans one range after another, it takes effect on the next Start.
+func (e *EventEngine) SetBackfillWorkers(workers int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.workers = workers
+}
+
+// SetDeadLetterStore keeps the events whose callbacks failed every retry, it takes effect on the next Start.
+func (e *EventEngine) SetDeadLetterStore(store DeadLetterStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deadLetters = store
 }
 
-func SubscribeExternal(
+// CallbackProblems returns the subscriptions whose callbacks were slow, timed out or panicked since Start.
+func (e *EventEngine) CallbackProblems() []CallbackStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dispatcher == nil {
+		return nil
+	}
+	return e.dispatcher.problems()
+}
+
+// forgetCallbacks drops the stats of removed subscriptions, so they do not pile up.
+func (e *EventEngine) forgetCallbacks(ids []uint64) {
+	e.mu.Lock()
+	d := e.dispatcher
+	e.mu.Unlock()
+	if d != nil {
+		d.forget(ids)
+	}
+}
+
+// SetFromBlock rescans from the block, it is safe to call while the engine is running.
+func (e *EventEngine) SetFromBlock(from uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.builder == nil {
+		dot.Logger().Warnln("Failed to set from block because of the engine is not started.")
+		return
+	}
+	e.builder.Rewind(from)
+}
+
+// QueryEvents reads past events without rewinding the live scanner, see events.Builder.QueryEvents.
+func (e *EventEngine) QueryEvents(
+	ctx context.Context,
+	from uint64,
+	to uint64,
+	names []string,
+	filter events2.EventFilter,
+) ([]events2.Event, error) {
+	e.mu.Lock()
+	builder := e.builder
+	e.mu.Unlock()
+	if builder == nil {
+		return nil, errors.New("couldn't query events because of the engine is not started")
+	}
+	return builder.QueryEvents(ctx, from, to, names, filter)
+}
+
+// Subscribe adds a callback for the event of the client, earlier callbacks stay subscribed.
+func (e *EventEngine) Subscribe(
 	clientAddr common.Address,
 	eventName string,
 	eventCallback EventCallback,
-) error {
-	return subscribe(clientAddr, eventName, eventCallback, externalEventRepo)
+) (*Subscription, error) {
+	return subscribe(clientAddr, eventName, eventCallback, e.repo)
 }
 
 func subscribe(
@@ -43,27 +211,20 @@ func subscribe(
 	eventName string,
 	eventCallback EventCallback,
 	eventRepo *EventRepository,
-) error {
+) (*Subscription, error) {
 	if eventCallback == nil || eventName == "" {
-		return errors.New("couldn't subscribe event because of null eventCallback or empty event name")
-	}
-
-	subscribeInfoMap := eventRepo.mapEventSubscribe[eventName]
-	if subscribeInfoMap == nil {
-		subscribeInfoMap = make(map[common.Address]EventCallback)
-		eventRepo.mapEventSubscribe[eventName] = subscribeInfoMap
+		return nil, errors.New("couldn't subscribe event because of null eventCallback or empty event name")
 	}
 
-	subscribeInfoMap[clientAddr] = eventCallback
-
-	return nil
+	return eventRepo.add([]string{eventName}, clientAddr, nil, eventCallback), nil
 }
 
-func UnSubscribeExternal(
+// UnSubscribe removes every callback of the client for the event.
+func (e *EventEngine) UnSubscribe(
 	clientAddr common.Address,
 	eventName string,
 ) error {
-	return unsubscribe(clientAddr, eventName, externalEventRepo)
+	return unsubscribe(clientAddr, eventName, e.repo)
 }
 
 func unsubscribe(
@@ -75,16 +236,9 @@ func unsubscribe(
 		return errors.New("couldn't unsubscribe event because of empty event name")
 	}
 
-	subscribeInfoMap := eventRepo.mapEventSubscribe[eventName]
-	if subscribeInfoMap == nil || subscribeInfoMap[clientAddr] == nil {
+	if !eventRepo.removeAll(eventName, clientAddr) {
 		return errors.New("couldn't find corresponding event to unsubscribe:" + eventName)
 	}
 
-	delete(subscribeInfoMap, clientAddr)
-	if len(subscribeInfoMap) == 0 {
-		subscribeInfoMap = nil
-		delete(eventRepo.mapEventSubscribe, eventName)
-	}
-
 	return nil
 }
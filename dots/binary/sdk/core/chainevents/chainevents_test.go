@@ -4,9 +4,13 @@
 package chainevents
 
 import (
+	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestUnSubscribeExternal(t *testing.T) {
@@ -17,16 +21,13 @@ func TestUnSubscribeExternal(t *testing.T) {
 
 	repo := NewEventRepository()
 
-	subMap1 := make(map[common.Address]EventCallback)
-	subMap1[addr1] = callback
-	subMap1[addr2] = callback
-
-	subMap2 := make(map[common.Address]EventCallback)
-	subMap2[addr1] = callback
-	subMap2[addr2] = callback
-
-	repo.mapEventSubscribe[evtName1] = subMap1
-	repo.mapEventSubscribe[evtName2] = subMap2
+	for _, evtName := range []string{evtName1, evtName2} {
+		for _, addr := range []common.Address{addr1, addr2} {
+			if _, err := subscribe(addr, evtName, callback, repo); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
 
 	unsubscribe(addr1, evtName1, repo)
 
@@ -48,3 +49,142 @@ func TestUnSubscribeExternal(t *testing.T) {
 func callback(event events2.Event) bool {
 	return true
 }
+
+func TestEnginesAreIndependent(t *testing.T) {
+	addr := common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+	engine1 := NewEventEngine(nil, nil, nil, false, "app")
+	engine2 := NewEventEngine(nil, nil, nil, false, "app")
+
+	if _, err := engine1.Subscribe(addr, "testEvent", callback); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine2.Subscribe(addr, "testEvent", callback); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine1.UnSubscribe(addr, "testEvent"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(engine1.repo.mapEventSubscribe["testEvent"]) != 0 {
+		t.Error("engine1 should have no subscriber")
+	}
+	if len(engine2.repo.mapEventSubscribe["testEvent"]) != 1 {
+		t.Error("engine2 should keep its subscriber")
+	}
+}
+
+func TestSeveralCallbacksPerAddress(t *testing.T) {
+	addr := common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+
+	var first, second int
+	sub1, _ := engine.Subscribe(addr, "Approval", func(event events2.Event) bool {
+		first++
+		return true
+	})
+	engine.Subscribe(addr, "Approval", func(event events2.Event) bool {
+		second++
+		return true
+	})
+
+	event := events2.Event{Name: "Approval", Data: events2.NewJSONObj()}
+	event.Data.Set(TARGET_OWNER, addr)
+	engine.executeEvent(event)
+	sub1.Unsubscribe()
+	sub1.Unsubscribe()
+	engine.executeEvent(event)
+
+	if first != 1 || second != 2 {
+		t.Errorf("want callbacks called 1 and 2 times, got %d and %d", first, second)
+	}
+}
+
+func TestRegistryConcurrentAccess(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	event := events2.Event{Name: "Approval", Data: events2.NewJSONObj()}
+	event.Data.Set(TARGET_USERS, []common.Address{common.HexToAddress(BROADCAST_TO_USERS)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			addr := common.BigToAddress(big.NewInt(int64(i)))
+			for j := 0; j < 100; j++ {
+				sub, err := engine.Subscribe(addr, "Approval", callback)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				sub.Unsubscribe()
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				engine.executeEvent(event)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(engine.repo.mapEventSubscribe); n != 0 {
+		t.Errorf("every subscription was removed, %d events left", n)
+	}
+}
+
+func TestProgressLagAndCancel(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+
+	var got []SyncProgress
+	cancel := engine.SubscribeProgress(func(p SyncProgress) {
+		got = append(got, p)
+	})
+
+	engine.updateProgress(events2.Progress{From: 1, To: 100, Head: 150, Events: 10}, time.Second)
+	p := engine.Progress()
+	if p.Current != 100 || p.Head != 150 || p.Lag != 50 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+	if p.EventsPerSecond <= 0 {
+		t.Errorf("events per second should grow, got %v", p.EventsPerSecond)
+	}
+
+	cancel()
+	engine.updateProgress(events2.Progress{From: 101, To: 150, Head: 150}, time.Second)
+	if len(got) != 1 {
+		t.Errorf("callback should stop after cancel, called %d times", len(got))
+	}
+	if engine.Progress().Lag != 0 {
+		t.Errorf("lag should be 0 at head, got %d", engine.Progress().Lag)
+	}
+}
+
+func TestPersistentFailure(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+
+	var last ScanFailure
+	engine.SubscribeErrors(func(f ScanFailure) {
+		last = f
+	})
+
+	rpcErr := &events2.ScanError{Kind: events2.ErrorRPC, Err: errors.New("connection refused")}
+	for i := 0; i < persistentFailureNum-1; i++ {
+		engine.handleError(rpcErr)
+	}
+	engine.handleError(&events2.ScanError{Kind: events2.ErrorDecode, Err: errors.New("short data")})
+	if last.Persistent || last.Kind != events2.ErrorDecode {
+		t.Fatalf("decode errors should not make a persistent failure, got %+v", last)
+	}
+
+	engine.handleError(rpcErr)
+	if !last.Persistent || last.Consecutive != persistentFailureNum {
+		t.Fatalf("want persistent failure after %d rpc errors, got %+v", persistentFailureNum, last)
+	}
+
+	engine.updateProgress(events2.Progress{From: 1, To: 10, Head: 10}, time.Second)
+	engine.handleError(rpcErr)
+	if last.Persistent || last.Consecutive != 1 {
+		t.Errorf("a scanned range should reset the failures, got %+v", last)
+	}
+}
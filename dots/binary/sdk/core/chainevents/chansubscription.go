@@ -0,0 +1,138 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/event"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"sync"
+)
+
+const defaultChannelBuffer = 64
+
+// OverflowPolicy decides what happens to an event when the buffer of a channel subscription is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the reader, later events of the subscription queue behind it.
+	// Past the dispatch timeout the events coming meanwhile go to the dead letters, other subscribers go on.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered event to make room.
+	OverflowDropOldest
+	// OverflowFail ends the subscription with ErrSubscriptionOverflow.
+	OverflowFail
+)
+
+var ErrSubscriptionOverflow = errors.New("event subscription buffer overflow")
+
+type ChannelConfig struct {
+	// Buffer is the capacity of the event channel, 0 for the default
+	Buffer   int
+	Overflow OverflowPolicy
+}
+
+type chanSubscription struct {
+	ch     chan events2.Event
+	err    chan error
+	policy OverflowPolicy
+	sub    *Subscription
+	quit   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+// SubscribeChannel is the channel form of Subscribe, modeled on go-ethereum event.Subscription.
+// Events come on the returned channel until ctx is done or the subscription is unsubscribed,
+// Err is closed on Unsubscribe and gets ErrSubscriptionOverflow when the fail policy ends it.
+func (e *EventEngine) SubscribeChannel(ctx context.Context, filter Filter, config ChannelConfig) (<-chan events2.Event, event.Subscription) {
+	if config.Buffer <= 0 {
+		config.Buffer = defaultChannelBuffer
+	}
+	cs := &chanSubscription{
+		ch:     make(chan events2.Event, config.Buffer),
+		err:    make(chan error, 1),
+		policy: config.Overflow,
+		quit:   make(chan struct{}),
+	}
+
+	if err := filter.validate(); err != nil {
+		cs.stop(err)
+		return cs.ch, cs
+	}
+	cs.sub = e.repo.add(filter.eventNames(), filter.Address, &filter, cs.deliver)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cs.Unsubscribe()
+		case <-cs.quit:
+		}
+	}()
+
+	return cs.ch, cs
+}
+
+func (cs *chanSubscription) Err() <-chan error {
+	return cs.err
+}
+
+func (cs *chanSubscription) Unsubscribe() {
+	cs.stop(nil)
+}
+
+func (cs *chanSubscription) stop(err error) {
+	cs.once.Do(func() {
+		if cs.sub != nil {
+			cs.sub.Unsubscribe()
+		}
+		close(cs.quit)
+		if err != nil {
+			cs.err <- err
+		}
+		close(cs.err)
+	})
+}
+
+// deliver returns false only when the event could not be handed over for a reason worth a retry,
+// events dropped on purpose by the overflow policy or after the subscription ended count as delivered.
+func (cs *chanSubscription) deliver(evt events2.Event) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	select {
+	case <-cs.quit:
+		return true
+	default:
+	}
+
+	switch cs.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case cs.ch <- evt:
+				return true
+			default:
+			}
+			select {
+			case <-cs.ch:
+			default:
+			}
+		}
+	case OverflowFail:
+		select {
+		case cs.ch <- evt:
+		default:
+			cs.stop(ErrSubscriptionOverflow)
+		}
+		return true
+	default:
+		select {
+		case cs.ch <- evt:
+		case <-cs.quit:
+		}
+		return true
+	}
+}
@@ -0,0 +1,106 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var chanAddr = common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+
+func approvalFor(addr common.Address, value int64) events2.Event {
+	event := events2.Event{Name: "Approval", Data: events2.NewJSONObj()}
+	event.Data.Set(TARGET_OWNER, addr)
+	event.Data.Set("value", big.NewInt(value))
+	return event
+}
+
+func TestChannelDropOldest(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	ch, sub := engine.SubscribeChannel(context.Background(), Filter{Names: []string{"Approval"}, Address: chanAddr},
+		ChannelConfig{Buffer: 2, Overflow: OverflowDropOldest})
+	defer sub.Unsubscribe()
+
+	for i := int64(1); i <= 3; i++ {
+		engine.executeEvent(approvalFor(chanAddr, i))
+	}
+	for _, want := range []int64{2, 3} {
+		if got := (<-ch).Data.Get("value").(*big.Int).Int64(); got != want {
+			t.Errorf("want value %d, got %d", want, got)
+		}
+	}
+}
+
+func TestChannelFail(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	_, sub := engine.SubscribeChannel(context.Background(), Filter{Address: chanAddr},
+		ChannelConfig{Buffer: 1, Overflow: OverflowFail})
+
+	engine.executeEvent(approvalFor(chanAddr, 1))
+	engine.executeEvent(approvalFor(chanAddr, 2))
+
+	if err := <-sub.Err(); err != ErrSubscriptionOverflow {
+		t.Errorf("want overflow error, got %v", err)
+	}
+	if _, ok := <-sub.Err(); ok {
+		t.Error("err channel should be closed")
+	}
+	if len(engine.repo.mapEventSubscribe) != 0 {
+		t.Error("failed subscription should be removed")
+	}
+}
+
+func TestChannelBlockEndsWithContext(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, sub := engine.SubscribeChannel(ctx, Filter{Names: []string{"Approval"}, Address: chanAddr},
+		ChannelConfig{Buffer: 1, Overflow: OverflowBlock})
+
+	engine.executeEvent(approvalFor(chanAddr, 1))
+	done := make(chan struct{})
+	go func() {
+		engine.executeEvent(approvalFor(chanAddr, 2))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("dispatch should wait for the reader")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cancelled context should release the dispatch")
+	}
+	if _, ok := <-sub.Err(); ok {
+		t.Error("err channel should be closed without error")
+	}
+	if len(ch) != 1 {
+		t.Errorf("want the first event buffered, got %d", len(ch))
+	}
+}
+
+func TestChannelDropIsNotAFailure(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	_, sub := engine.SubscribeChannel(context.Background(), Filter{Names: []string{"Approval"}, Address: chanAddr},
+		ChannelConfig{Buffer: 1, Overflow: OverflowFail})
+	cs := sub.(*chanSubscription)
+
+	for v := int64(1); v <= 3; v++ {
+		if !cs.deliver(approvalFor(chanAddr, v)) {
+			t.Errorf("event %d dropped by the overflow policy should not be retried", v)
+		}
+	}
+	sub.Unsubscribe()
+	if !cs.deliver(approvalFor(chanAddr, 4)) {
+		t.Error("event after unsubscribe should not be retried")
+	}
+}
@@ -0,0 +1,220 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/scryinfo/dot/dot"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DeadLetter is an event a callback still failed after every retry,
+// it keeps the log position, the event itself is queried again on replay.
+// EventName and ClientAddr are stable, the subscription id is only valid in the Session that wrote it.
+type DeadLetter struct {
+	EventName  string
+	ClientAddr common.Address
+	Contract   common.Address
+	Block      uint64
+	TxHash     common.Hash
+	LogIndex   uint
+	// SubscriptionID finds the failed callback again as long as the engine of Session runs,
+	// a replay in another process or engine ignores it
+	SubscriptionID uint64
+	Session        string
+	Attempts       int
+	Reason         string
+	Time           int64
+}
+
+func (dl DeadLetter) sameAs(other DeadLetter) bool {
+	return dl.EventName == other.EventName && dl.ClientAddr == other.ClientAddr &&
+		dl.TxHash == other.TxHash && dl.LogIndex == other.LogIndex
+}
+
+type DeadLetterStore interface {
+	// Add replaces the letter of the same event and client
+	Add(letter DeadLetter) error
+	List() ([]DeadLetter, error)
+	Remove(letter DeadLetter) error
+}
+
+type FileDeadLetterStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileDeadLetterStore(path string) *FileDeadLetterStore {
+	return &FileDeadLetterStore{path: path}
+}
+
+func (fs *FileDeadLetterStore) Add(letter DeadLetter) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	letters, err := fs.load()
+	if err != nil {
+		return err
+	}
+	return fs.save(append(without(letters, letter), letter))
+}
+
+func (fs *FileDeadLetterStore) List() ([]DeadLetter, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.load()
+}
+
+func (fs *FileDeadLetterStore) Remove(letter DeadLetter) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	letters, err := fs.load()
+	if err != nil {
+		return err
+	}
+	return fs.save(without(letters, letter))
+}
+
+func (fs *FileDeadLetterStore) load() ([]DeadLetter, error) {
+	data, err := ioutil.ReadFile(fs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var letters []DeadLetter
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if err = json.Unmarshal(data, &letters); err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// save writes to a temp file first and renames it, like the scan checkpoint.
+func (fs *FileDeadLetterStore) save(letters []DeadLetter) error {
+	data, err := json.MarshalIndent(letters, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(fs.path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := fs.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.path)
+}
+
+func without(letters []DeadLetter, letter DeadLetter) []DeadLetter {
+	var left []DeadLetter
+	for _, l := range letters {
+		if !l.sameAs(letter) {
+			left = append(left, l)
+		}
+	}
+	return left
+}
+
+func (e *EventEngine) DeadLetters() ([]DeadLetter, error) {
+	e.mu.Lock()
+	store := e.deadLetters
+	e.mu.Unlock()
+	if store == nil {
+		return nil, errors.New("no dead letter store")
+	}
+	return store.List()
+}
+
+// ReplayDeadLetters queries the events of the dead letters again and calls the failed callbacks once more,
+// letters are removed when the callbacks succeed or the event is no longer on the chain.
+func (e *EventEngine) ReplayDeadLetters(ctx context.Context) (replayed int, err error) {
+	e.mu.Lock()
+	store, d, quit := e.deadLetters, e.dispatcher, e.quit
+	e.mu.Unlock()
+	if store == nil {
+		return 0, errors.New("no dead letter store")
+	}
+	if d == nil || quit == nil {
+		return 0, errors.New("couldn't replay dead letters because of the engine is not started")
+	}
+
+	letters, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+	for _, letter := range letters {
+		letter := letter
+		evts, err := e.QueryEvents(ctx, letter.Block, letter.Block, []string{letter.EventName}, func(evt events2.Event) bool {
+			return evt.Address == letter.Contract && evt.TxHash == letter.TxHash && evt.LogIndex == letter.LogIndex
+		})
+		if err != nil {
+			return replayed, err
+		}
+		if len(evts) == 0 {
+			dot.Logger().Warnln("dead letter event is not on the chain any more, drop it", zap.String("tx", letter.TxHash.Hex()))
+		} else if ok, gone := e.replay(d, letter, evts[0], quit); gone {
+			dot.Logger().Warnln("subscription of dead letter was removed, drop it", zap.String("tx", letter.TxHash.Hex()))
+		} else if !ok {
+			letter.Attempts++
+			if err = store.Add(letter); err != nil {
+				return replayed, err
+			}
+			continue
+		} else {
+			replayed++
+		}
+		if err = store.Remove(letter); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// replay runs the subscription that failed when the letter was written by this engine,
+// gone is true when that subscription was removed since, the other callbacks got the event already.
+// A letter of an earlier process runs every callback of the client for the event whose app and filter select it,
+// as its subscription id may name another callback now.
+func (e *EventEngine) replay(d *dispatcher, letter DeadLetter, event events2.Event, quit <-chan struct{}) (ok bool, gone bool) {
+	var subs []subscriber
+	appId := e.AppId()
+	sameSession := letter.Session == e.repo.session
+	for _, s := range e.repo.subscribers(letter.EventName)[letter.ClientAddr] {
+		if sameSession {
+			if s.id == letter.SubscriptionID {
+				subs = []subscriber{s}
+				break
+			}
+			continue
+		}
+		if fromApp(s, event, appId) && (s.filter == nil || s.filter.matches(event)) {
+			subs = append(subs, s)
+		}
+	}
+	if len(subs) == 0 {
+		return false, sameSession
+	}
+
+	ok = true
+	for _, s := range subs {
+		if !d.call(s, event, quit) {
+			ok = false
+		}
+	}
+	return ok, false
+}
@@ -0,0 +1,97 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryThenDeadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileDeadLetterStore(filepath.Join(dir, "events.deadletter"))
+
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	d := newDispatcher(DispatchConfig{Attempts: 3, Backoff: time.Millisecond}, store)
+	d.session = engine.repo.session
+	quit := make(chan struct{})
+	defer close(quit)
+
+	calls := 0
+	flaky := subscriber{id: 1, eventName: "Approval", clientAddr: chanAddr, callback: func(event events2.Event) bool {
+		calls++
+		return calls == 2
+	}}
+	event := approvalFor(chanAddr, 1)
+	event.TxHash = common.HexToHash("0x01")
+	d.deliver(flaky, event, quit)
+	if calls != 2 {
+		t.Fatalf("want success on the second attempt, got %d calls", calls)
+	}
+
+	failing := subscriber{id: 2, eventName: "Approval", clientAddr: chanAddr, callback: func(event events2.Event) bool {
+		calls++
+		return false
+	}}
+	calls = 0
+	d.deliver(failing, event, quit)
+	d.deliver(failing, event, quit)
+	if calls != 6 {
+		t.Fatalf("want 3 attempts per delivery, got %d calls", calls)
+	}
+
+	letters, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].TxHash != event.TxHash || letters[0].Attempts != 3 || letters[0].SubscriptionID != 2 {
+		t.Fatalf("want one dead letter of the failed event, got %+v", letters)
+	}
+
+	// a replay runs only the subscription that failed
+	var replayed []string
+	engine.Subscribe(chanAddr, "Approval", func(event events2.Event) bool {
+		replayed = append(replayed, "first")
+		return true
+	})
+	letters[0].SubscriptionID = 2
+	second, _ := engine.Subscribe(chanAddr, "Approval", func(event events2.Event) bool {
+		replayed = append(replayed, "second")
+		return true
+	})
+	if ok, _ := engine.replay(d, letters[0], event, quit); !ok || len(replayed) != 1 || replayed[0] != "second" {
+		t.Errorf("want only the failed subscription replayed, got %v", replayed)
+	}
+
+	// the id of a letter written by another process may name another callback now
+	replayed = nil
+	restarted := letters[0]
+	restarted.Session = "earlier process"
+	if ok, _ := engine.replay(d, restarted, event, quit); !ok || len(replayed) != 2 {
+		t.Errorf("want every callback of the client replayed after a restart, got %v", replayed)
+	}
+
+	// the other callbacks of this process got the event already
+	replayed = nil
+	second.Unsubscribe()
+	if ok, gone := engine.replay(d, letters[0], event, quit); ok || !gone || len(replayed) != 0 {
+		t.Errorf("want the letter of a removed subscription dropped without calls, got %v", replayed)
+	}
+
+	if err = store.Remove(letters[0]); err != nil {
+		t.Fatal(err)
+	}
+	if letters, _ = store.List(); len(letters) != 0 {
+		t.Errorf("want no dead letter left, got %d", len(letters))
+	}
+}
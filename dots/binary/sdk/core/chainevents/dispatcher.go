@@ -0,0 +1,349 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/scryinfo/dot/dot"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDispatchWorkers = 8
+	defaultCallbackTimeout = 30 * time.Second
+	defaultSlowCallback    = 5 * time.Second
+	defaultAttempts        = 3
+	defaultBackoff         = time.Second
+	defaultMaxBackoff      = 30 * time.Second
+	dispatchQueueSize      = 256
+	// laneIdle is how long the goroutine of a subscription waits for events before it exits
+	laneIdle = time.Minute
+)
+
+type DispatchConfig struct {
+	// Workers is the number of callbacks running at once, the callbacks of one subscription run one after another
+	Workers int
+	// Timeout is how long a callback runs before it is counted as timed out and leaves its worker to the others,
+	// the later events of the subscription wait in order until it returns
+	Timeout time.Duration
+	// Slow callbacks are counted in the stats
+	Slow time.Duration
+	// Attempts a callback gets when it returns false or panics, the event then goes to the dead letters
+	Attempts int
+	// Backoff before the first retry, it doubles up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// CallbackStats records the callbacks of one subscription that misbehaved.
+type CallbackStats struct {
+	EventName  string
+	ClientAddr common.Address
+	Calls      uint64
+	Slow       uint64
+	TimedOut   uint64
+	Panics     uint64
+	// Failed counts the calls that returned false
+	Failed       uint64
+	DeadLettered uint64
+	// LastPanic is the recovered value of the latest panic
+	LastPanic string
+}
+
+type dispatchJob struct {
+	s     subscriber
+	event events2.Event
+	// reply gets the result of a single call without retries, nil for dispatched events
+	reply chan bool
+	// done is called once a dispatched event was delivered or went to the dead letters
+	done func()
+}
+
+// lane delivers the events of one subscription in order.
+type lane struct {
+	queue chan dispatchJob
+	// pending counts the jobs sent to or being sent to queue and not delivered yet, guarded by dispatcher.mu
+	pending int
+}
+
+// dispatcher keeps the event order of every subscription while different subscriptions run in parallel.
+type dispatcher struct {
+	config      DispatchConfig
+	deadLetters DeadLetterStore
+	// session of the repository the subscription ids come from, kept with the dead letters
+	session string
+	slots   chan struct{}
+	lanes   map[uint64]*lane
+	stats   map[uint64]*CallbackStats
+	mu      sync.Mutex
+}
+
+func newDispatcher(config DispatchConfig, deadLetters DeadLetterStore) *dispatcher {
+	if config.Workers <= 0 {
+		config.Workers = defaultDispatchWorkers
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultCallbackTimeout
+	}
+	if config.Slow <= 0 || config.Slow > config.Timeout {
+		config.Slow = defaultSlowCallback
+	}
+	if config.Attempts <= 0 {
+		config.Attempts = defaultAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaultBackoff
+	}
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+	return &dispatcher{
+		config:      config,
+		deadLetters: deadLetters,
+		slots:       make(chan struct{}, config.Workers),
+		lanes:       make(map[uint64]*lane),
+		stats:       make(map[uint64]*CallbackStats),
+	}
+}
+
+// dispatch waits when the subscription is too far behind, a timed out callback still running holds
+// the later events of its subscription in order. done can be nil, it is not called for events left behind by quit.
+func (d *dispatcher) dispatch(s subscriber, event events2.Event, quit <-chan struct{}, done func()) {
+	d.enqueue(dispatchJob{s: s, event: event, done: done}, quit)
+}
+
+// call runs the callback once in the order of the other events of the subscription.
+func (d *dispatcher) call(s subscriber, event events2.Event, quit <-chan struct{}) bool {
+	reply := make(chan bool, 1)
+	if !d.enqueue(dispatchJob{s: s, event: event, reply: reply}, quit) {
+		return false
+	}
+	select {
+	case ok := <-reply:
+		return ok
+	case <-quit:
+		return false
+	}
+}
+
+// enqueue is false when quit stopped it before the job was queued.
+func (d *dispatcher) enqueue(job dispatchJob, quit <-chan struct{}) bool {
+	d.mu.Lock()
+	l := d.lanes[job.s.id]
+	if l == nil {
+		l = &lane{queue: make(chan dispatchJob, dispatchQueueSize)}
+		d.lanes[job.s.id] = l
+		go d.work(job.s.id, l, quit)
+	}
+	l.pending++
+	d.mu.Unlock()
+
+	select {
+	case l.queue <- job:
+		return true
+	case <-quit:
+		d.mu.Lock()
+		l.pending--
+		d.mu.Unlock()
+		return false
+	}
+}
+
+// work delivers the events of one subscription, it exits when the lane stays empty for laneIdle.
+func (d *dispatcher) work(id uint64, l *lane, quit <-chan struct{}) {
+	for {
+		select {
+		case job := <-l.queue:
+			if job.reply != nil {
+				ok, _ := d.invoke(job.s, job.event, quit)
+				job.reply <- ok
+			} else if d.deliver(job.s, job.event, quit) && job.done != nil {
+				job.done()
+			}
+			d.mu.Lock()
+			l.pending--
+			d.mu.Unlock()
+		case <-time.After(laneIdle):
+			d.mu.Lock()
+			if l.pending == 0 {
+				delete(d.lanes, id)
+				d.mu.Unlock()
+				return
+			}
+			d.mu.Unlock()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// deliver retries a failed callback with backoff, the retries hold back the later events of the subscription.
+// It is false when quit stopped it before the event was delivered or dead lettered.
+func (d *dispatcher) deliver(s subscriber, event events2.Event, quit <-chan struct{}) bool {
+	backoff := d.config.Backoff
+	for attempt := 1; ; attempt++ {
+		ok, reason := d.invoke(s, event, quit)
+		if ok {
+			return true
+		}
+		select {
+		case <-quit:
+			return false
+		default:
+		}
+		if attempt >= d.config.Attempts {
+			d.deadLetter(s, event, attempt, reason)
+			return true
+		}
+		select {
+		case <-time.After(backoff):
+		case <-quit:
+			return false
+		}
+		if backoff *= 2; backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
+
+// invoke runs the callback on one of the worker slots. A callback can not be stopped,
+// after the timeout it leaves the slot to the others while its subscription keeps waiting for it.
+// reason says why the call failed.
+func (d *dispatcher) invoke(s subscriber, event events2.Event, quit <-chan struct{}) (ok bool, reason string) {
+	select {
+	case d.slots <- struct{}{}:
+	case <-quit:
+		return false, "dispatcher stopped"
+	}
+	slot := true
+	release := func() {
+		if slot {
+			<-d.slots
+			slot = false
+		}
+	}
+	defer release()
+
+	type result struct {
+		ok bool
+		er interface{}
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		var r result
+		defer func() {
+			r.er = recover()
+			done <- r
+		}()
+		r.ok = s.callback(event)
+	}()
+
+	timer := time.NewTimer(d.config.Timeout)
+	defer timer.Stop()
+	var r result
+	select {
+	case r = <-done:
+	case <-timer.C:
+		d.record(s, func(stats *CallbackStats) {
+			stats.TimedOut++
+		})
+		dot.Logger().Warnln("callback of event " + event.Name + " timed out, address: " + s.clientAddr.Hex())
+		release()
+		select {
+		case r = <-done:
+		case <-quit:
+			return false, "dispatcher stopped"
+		}
+	case <-quit:
+		return false, "dispatcher stopped"
+	}
+
+	elapsed := time.Since(start)
+	d.record(s, func(stats *CallbackStats) {
+		stats.Calls++
+		if r.er != nil {
+			stats.Panics++
+			stats.LastPanic = fmt.Sprint(r.er)
+		} else if !r.ok {
+			stats.Failed++
+		}
+		if elapsed >= d.config.Slow {
+			stats.Slow++
+		}
+	})
+	if r.er != nil {
+		dot.Logger().Errorln("", zap.Any("error: failed to execute event "+event.Name+" because of error: ", r.er))
+		return false, fmt.Sprint("panic: ", r.er)
+	}
+	if !r.ok {
+		return false, "callback returned false"
+	}
+	return true, ""
+}
+
+func (d *dispatcher) deadLetter(s subscriber, event events2.Event, attempts int, reason string) {
+	d.record(s, func(stats *CallbackStats) {
+		stats.DeadLettered++
+	})
+	letter := DeadLetter{
+		EventName:      event.Name,
+		ClientAddr:     s.clientAddr,
+		Contract:       event.Address,
+		Block:          event.BlockNumber,
+		TxHash:         event.TxHash,
+		LogIndex:       event.LogIndex,
+		SubscriptionID: s.id,
+		Session:        d.session,
+		Attempts:       attempts,
+		Reason:         reason,
+		Time:           time.Now().Unix(),
+	}
+	dot.Logger().Errorln("event goes to dead letters", zap.String("event", event.String()), zap.String("reason", reason))
+	if d.deadLetters == nil {
+		return
+	}
+	if err := d.deadLetters.Add(letter); err != nil {
+		dot.Logger().Errorln("", zap.NamedError("failed to save dead letter, error: ", err))
+	}
+}
+
+func (d *dispatcher) record(s subscriber, update func(stats *CallbackStats)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := d.stats[s.id]
+	if stats == nil {
+		stats = &CallbackStats{EventName: s.eventName, ClientAddr: s.clientAddr}
+		d.stats[s.id] = stats
+	}
+	update(stats)
+}
+
+// forget drops the stats of removed subscriptions.
+func (d *dispatcher) forget(ids []uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range ids {
+		delete(d.stats, id)
+	}
+}
+
+// problems returns the stats of the subscriptions that had slow, timed out, failed or panicking callbacks.
+func (d *dispatcher) problems() []CallbackStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var list []CallbackStats
+	for _, stats := range d.stats {
+		if stats.Slow+stats.TimedOut+stats.Panics+stats.Failed > 0 {
+			list = append(list, *stats)
+		}
+	}
+	return list
+}
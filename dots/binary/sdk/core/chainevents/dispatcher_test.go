@@ -0,0 +1,191 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchKeepsOrderPerSubscriber(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 4, Timeout: time.Second}, nil)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	var mu sync.Mutex
+	got := make(map[uint64][]int64)
+	var wg sync.WaitGroup
+	subs := make([]subscriber, 4)
+	for i := range subs {
+		id := uint64(i + 1)
+		subs[i] = subscriber{id: id, callback: func(event events2.Event) bool {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			got[id] = append(got[id], event.Data.Get("value").(*big.Int).Int64())
+			return true
+		}}
+	}
+
+	for v := int64(0); v < 50; v++ {
+		for _, s := range subs {
+			wg.Add(1)
+			d.dispatch(s, approvalFor(common.Address{}, v), quit, nil)
+		}
+	}
+	wg.Wait()
+
+	for id, values := range got {
+		for i, v := range values {
+			if v != int64(i) {
+				t.Fatalf("subscriber %d got %v out of order", id, values)
+			}
+		}
+	}
+}
+
+func TestSlowSubscriberDoesNotStallOthers(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 2, Timeout: 50 * time.Millisecond, Slow: 10 * time.Millisecond, Attempts: 1}, nil)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := subscriber{id: 1, eventName: "Approval", callback: func(event events2.Event) bool {
+		<-release
+		return true
+	}}
+	fast := make(chan struct{}, 1)
+	other := subscriber{id: 2, callback: func(event events2.Event) bool {
+		fast <- struct{}{}
+		return true
+	}}
+	panicking := subscriber{id: 3, callback: func(event events2.Event) bool {
+		panic("broken callback")
+	}}
+
+	event := approvalFor(common.Address{}, 1)
+	d.dispatch(slow, event, quit, nil)
+	d.dispatch(other, event, quit, nil)
+	select {
+	case <-fast:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber on another worker should not wait for the slow one")
+	}
+
+	d.dispatch(panicking, event, quit, nil)
+	time.Sleep(200 * time.Millisecond)
+
+	problems := make(map[string]CallbackStats)
+	for _, stats := range d.problems() {
+		problems[stats.EventName+stats.LastPanic] = stats
+	}
+	if stats := problems["Approval"]; stats.TimedOut != 1 {
+		t.Errorf("want the slow callback timed out once, got %+v", stats)
+	}
+	if stats := problems["broken callback"]; stats.Panics != 1 {
+		t.Errorf("want the panic recorded, got %+v", stats)
+	}
+}
+
+func TestTimedOutCallbackHoldsItsSubscription(t *testing.T) {
+	d := newDispatcher(DispatchConfig{Workers: 1, Timeout: 20 * time.Millisecond, Slow: 10 * time.Millisecond, Attempts: 1}, nil)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	release, started := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var got []int64
+	running := 0
+	stuck := subscriber{id: 1, callback: func(event events2.Event) bool {
+		mu.Lock()
+		running++
+		concurrent := running > 1
+		mu.Unlock()
+		if concurrent {
+			t.Error("callbacks of one subscription should never run at once")
+		}
+		if event.Data.Get("value").(*big.Int).Int64() == 1 {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		running--
+		got = append(got, event.Data.Get("value").(*big.Int).Int64())
+		mu.Unlock()
+		return true
+	}}
+	other := make(chan struct{}, 1)
+	fast := subscriber{id: 2, callback: func(event events2.Event) bool {
+		other <- struct{}{}
+		return true
+	}}
+
+	d.dispatch(stuck, approvalFor(common.Address{}, 1), quit, nil)
+	d.dispatch(stuck, approvalFor(common.Address{}, 2), quit, nil)
+	<-started
+	d.dispatch(fast, approvalFor(common.Address{}, 1), quit, nil)
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("a timed out callback should leave its worker to other subscriptions")
+	}
+
+	// a new event waits behind the timed out one instead of being dead lettered
+	d.dispatch(stuck, approvalFor(common.Address{}, 3), quit, nil)
+	mu.Lock()
+	if len(got) != 0 {
+		t.Errorf("event 2 should wait for the timed out event 1, got %v", got)
+	}
+	mu.Unlock()
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("want events 1, 2 and 3 in order, got %v", got)
+	}
+	for _, stats := range d.problems() {
+		if stats.DeadLettered != 0 || stats.TimedOut != 1 {
+			t.Errorf("want one timeout and no dead letter, got %+v", stats)
+		}
+	}
+}
+
+func TestUnsubscribeForgetsCallbackStats(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	d := newDispatcher(DispatchConfig{}, nil)
+	engine.dispatcher = d
+
+	kept, _ := engine.Subscribe(chanAddr, "Approval", func(event events2.Event) bool { return false })
+	removed, _ := engine.Subscribe(chanAddr, "Approval", func(event events2.Event) bool { return false })
+	for _, s := range engine.repo.subscribers("Approval")[chanAddr] {
+		d.record(s, func(stats *CallbackStats) { stats.Failed++ })
+	}
+
+	removed.Unsubscribe()
+	if len(d.stats) != 1 || d.stats[kept.id] == nil {
+		t.Errorf("want only the stats of the kept subscription, got %v", d.stats)
+	}
+	if err := engine.UnSubscribe(chanAddr, "Approval"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.stats) != 0 {
+		t.Errorf("want no stats left, got %v", d.stats)
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/scryinfo/dot/dot"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
-	settings2 "github.com/scryinfo/dp/dots/binary/sdk/settings"
 	"go.uber.org/zap"
+	"sync/atomic"
 )
 
 const (
@@ -16,80 +16,132 @@ const (
 	TARGET_USERS       = "users"
 	TARGET_OWNER       = "owner"
 	APP_SEQ_NO         = "seqNo"
+	TARGET_FROM        = "from"
+	TARGET_TO          = "to"
 	TOKEN_EVT_APPROVAL = "Approval"
+	TOKEN_EVT_TRANSFER = "Transfer"
 )
 
-func ExecuteEvents(dataChannel chan events2.Event, externalEventRepo *EventRepository) {
-	defer func() {
-		if er := recover(); er != nil {
-			dot.Logger().Errorln("", zap.Any("Error: failed to execute event, error: ", er))
-		}
-	}()
-
+// executeEvents acknowledges an event to the scanner once every subscriber got it,
+// so the scan checkpoint never moves past events still waiting in the dispatcher.
+func (e *EventEngine) executeEvents(data <-chan events2.Event, dispatcher *dispatcher, builder *events2.Builder, quit <-chan struct{}) {
 	for {
 		select {
-		case event := <-dataChannel:
+		case event := <-data:
 			dot.Logger().Debugln("event coming:" + event.String())
-			executeEvent(event, externalEventRepo)
+			var subs []subscriber
+			e.dispatchEvent(event, func(s subscriber) {
+				subs = append(subs, s)
+			})
+			if len(subs) == 0 {
+				builder.Ack(event)
+				continue
+			}
+			left := int32(len(subs))
+			done := func() {
+				if atomic.AddInt32(&left, -1) == 0 {
+					builder.Ack(event)
+				}
+			}
+			for _, s := range subs {
+				dispatcher.dispatch(s, event, quit, done)
+			}
+		case <-quit:
+			return
 		}
 	}
 }
 
-func executeEvent(event events2.Event, eventRepo *EventRepository) bool {
-	defer func() {
-		if er := recover(); er != nil {
-			dot.Logger().Errorln("", zap.Any("error: failed to execute event "+event.Name+" because of error: ", er))
-		}
-	}()
+// executeEvent runs the callbacks of the event one after another on the calling goroutine.
+func (e *EventEngine) executeEvent(event events2.Event) bool {
+	return e.dispatchEvent(event, func(s subscriber) {
+		defer func() {
+			if er := recover(); er != nil {
+				dot.Logger().Errorln("", zap.Any("error: failed to execute event "+event.Name+" because of error: ", er))
+			}
+		}()
+		s.callback(event)
+	})
+}
 
-	subscribeInfoMap := eventRepo.mapEventSubscribe[event.Name]
+// dispatchEvent calls run for every subscriber the event is routed to.
+// Plain subscribers get the events of the engine app that name them in users or owner,
+// filtered subscribers choose their apps, can widen that to any address and narrow it with predicates.
+func (e *EventEngine) dispatchEvent(event events2.Event, run func(s subscriber)) bool {
+	subscribeInfoMap := e.repo.subscribers(event.Name)
 	if subscribeInfoMap == nil {
 		dot.Logger().Warnln("warning: no event was executed, event:" + event.Name)
 		return false
 	}
 
-	seqNo := event.Data.Get(APP_SEQ_NO)
-	if seqNo != settings2.GetAppId() && event.Name != TOKEN_EVT_APPROVAL {
-		return true
+	appId := e.AppId()
+	users, routed := eventTargets(event)
+	if !routed {
+		dot.Logger().Warnln("Warning: unknown event type, event:" + event.Name)
 	}
 
-	objUsers := event.Data.Get(TARGET_USERS)
-	if objUsers != nil {
-		users := objUsers.([]common.Address)
-		if len(users) == 1 && users[0] == common.HexToAddress(BROADCAST_TO_USERS) {
-			executeAllEvent(subscribeInfoMap, event)
-		} else {
-			executeMatchedEvent(subscribeInfoMap, users, event)
-		}
-
-	} else {
-		obj, ok := event.Data.Get(TARGET_OWNER).(string)
-		if ok {
-			owner := common.HexToAddress(obj)
-			executeMatchedEvent(subscribeInfoMap, []common.Address{owner}, event)
-		} else {
-			dot.Logger().Warnln("Warning: unknown event type, event:" + event.Name)
+	for addr, subs := range subscribeInfoMap {
+		toAddr := routed && (users == nil || containUser(users, addr))
+		for _, s := range subs {
+			if !fromApp(s, event, appId) {
+				continue
+			}
+			if s.filter == nil {
+				if toAddr {
+					run(s)
+				}
+				continue
+			}
+			if (toAddr || s.filter.AnyAddress) && s.filter.matches(event) {
+				run(s)
+			}
 		}
 	}
 
 	return true
 }
 
-func executeMatchedEvent(subscribeInfoMap map[common.Address]EventCallback,
-	users []common.Address, event events2.Event) {
-	for k, v := range subscribeInfoMap {
-		if containUser(users, k) {
-			if v != nil {
-				EventCallback(v)(event)
-			}
-		}
+// fromApp tells whether the subscriber gets the events of the app that stamped event, appId is the app of the engine.
+func fromApp(s subscriber, event events2.Event, appId string) bool {
+	// token events are not stamped with an app
+	if event.Name == TOKEN_EVT_APPROVAL || event.Name == TOKEN_EVT_TRANSFER {
+		return true
 	}
+	seqNo := event.Data.Get(APP_SEQ_NO)
+	if s.filter == nil {
+		return seqNo == appId
+	}
+	return s.filter.acceptsApp(seqNo, appId)
 }
 
-func executeAllEvent(subscribeInfoMap map[common.Address]EventCallback, event events2.Event) {
-	for _, v := range subscribeInfoMap {
-		EventCallback(v)(event)
+// eventTargets returns the users the event is routed to, nil users is a broadcast to everyone.
+// routed is false for events without users or owner. A token transfer goes to both sides.
+func eventTargets(event events2.Event) (users []common.Address, routed bool) {
+	if event.Name == TOKEN_EVT_TRANSFER {
+		from, okFrom := event.Data.Get(TARGET_FROM).(common.Address)
+		to, okTo := event.Data.Get(TARGET_TO).(common.Address)
+		if !okFrom || !okTo {
+			return nil, false
+		}
+		return []common.Address{from, to}, true
+	}
+
+	objUsers := event.Data.Get(TARGET_USERS)
+	if objUsers != nil {
+		if users, routed = objUsers.([]common.Address); !routed {
+			return nil, false
+		}
+		if len(users) == 1 && users[0] == common.HexToAddress(BROADCAST_TO_USERS) {
+			return nil, true
+		}
+		return append([]common.Address{}, users...), true
+	}
+
+	owner, ok := event.Data.Get(TARGET_OWNER).(common.Address)
+	if ok {
+		return []common.Address{owner}, true
 	}
+	return nil, false
 }
 
 func containUser(userList []common.Address, user common.Address) bool {
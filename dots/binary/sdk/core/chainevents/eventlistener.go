@@ -4,16 +4,12 @@
 package chainevents
 
 import (
+	"context"
+	"errors"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/scryinfo/dot/dot"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
 	"go.uber.org/zap"
-	"time"
-)
-
-var (
-	builder *events2.Builder = nil
 )
 
 type ContractInfo struct {
@@ -22,50 +18,80 @@ type ContractInfo struct {
 	Events  []string
 }
 
-func ListenEvent(conn *ethclient.Client, contracts []ContractInfo, fromBlock uint64, interval time.Duration,
-	dataChannel chan events2.Event, errorChannel chan error) bool {
+// listen builds the scanner and runs it in background, it is called with e.mu held.
+func (e *EventEngine) listen() error {
 	logger := dot.Logger()
-	rv := true
 	logger.Infoln("start listening events...")
 
-	defer func() {
-		if er := recover(); er != nil {
-			logger.Errorln("", zap.Any("Failed to listen event. error:", er))
-			rv = false
-		}
-	}()
-
-	if len(contracts) == 0 {
-		logger.Errorln("invalid contracts parameter")
-		return false
+	if len(e.contracts) == 0 {
+		return errors.New("invalid contracts parameter")
 	}
 
-	builder = events2.NewScanBuilder()
-	for _, v := range contracts {
+	builder := events2.NewScanBuilder()
+	for _, v := range e.contracts {
 		builder.SetContract(common.HexToAddress(v.Address), v.Abi, v.Events...)
 	}
 
-	recp, err := builder.SetClient(conn).
-		SetFrom(fromBlock).
+	recp, err := builder.SetClient(e.conn).
+		SetFrom(0).
 		SetTo(0).
+		SetCheckpointStore(e.checkpoint).
+		SetPushMode(e.push).
+		SetBackfillWorkers(e.workers).
 		SetGracefullExit(true).
-		SetDataChan(dataChannel, errorChannel).
-		SetInterval(interval).
+		SetDataChan(e.dataChannel, e.errorChannel).
+		SetProgressChan(e.progressChannel).
+		SetInterval(e.interval).
 		BuildAndRun()
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to listen to events.", err))
-		return false
+		return err
 	}
 
-	recp.WaitChan()
-
-	return rv
+	e.builder, e.recipet = builder, recp
+	return nil
 }
 
-func SetFromBlock(from uint64) {
+// AddContract watches another contract without restarting, its events from block from are backfilled,
+// nil backfills nothing. A stopped engine only remembers the contract for the next Start.
+// A failed backfill returns a *events.ScanError, the contract is watched nevertheless.
+func (e *EventEngine) AddContract(ctx context.Context, contract ContractInfo, from *uint64) error {
+	e.mu.Lock()
+	builder := e.builder
+	if e.quit == nil {
+		builder = nil
+	}
+	e.mu.Unlock()
+
+	var err error
 	if builder != nil {
-		builder.SetFrom(from)
-	} else {
-		dot.Logger().Warnln("Failed to set from block because of nil builder.")
+		err = builder.AddContract(ctx, common.HexToAddress(contract.Address), contract.Abi, from, contract.Events...)
+		if _, watched := err.(*events2.ScanError); err != nil && !watched {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.contracts = append(e.contracts, contract)
+	return err
+}
+
+func (e *EventEngine) RemoveContract(address common.Address) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.quit != nil {
+		if err := e.builder.RemoveContract(address); err != nil {
+			return err
+		}
+	}
+	var left []ContractInfo
+	for _, c := range e.contracts {
+		if common.HexToAddress(c.Address) != address {
+			left = append(left, c)
+		}
 	}
+	e.contracts = left
+	return nil
 }
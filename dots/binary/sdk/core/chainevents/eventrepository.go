@@ -4,18 +4,176 @@
 package chainevents
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/ethereum/go-ethereum/common"
 	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"sync"
 )
 
 type EventCallback func(event events2.Event) bool
 
+// anyEvent is the repository key of callbacks that get every event
+const anyEvent = ""
+
+type subscriber struct {
+	id         uint64
+	eventName  string
+	clientAddr common.Address
+	// filter is nil for the plain subscriptions routed by users, owner and app seqNo
+	filter   *Filter
+	callback EventCallback
+}
+
+// EventRepository keeps the callbacks by event name and client address,
+// it is safe for concurrent subscribe, unsubscribe and event dispatch.
 type EventRepository struct {
-	mapEventSubscribe map[string]map[common.Address]EventCallback
+	mapEventSubscribe map[string]map[common.Address][]subscriber
+	nextID            uint64
+	// session tells the subscription ids of this repository from those of another one or an earlier process
+	session string
+	// removed is called outside mu with the ids that have no callback left
+	removed func(ids []uint64)
+	mu      sync.RWMutex
 }
 
 func NewEventRepository() *EventRepository {
 	return &EventRepository{
-		mapEventSubscribe: make(map[string]map[common.Address]EventCallback),
+		mapEventSubscribe: make(map[string]map[common.Address][]subscriber),
+		session:           newSession(),
+	}
+}
+
+func newSession() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Subscription is returned by Subscribe, Unsubscribe removes only its own callback.
+type Subscription struct {
+	repo       *EventRepository
+	eventNames []string
+	clientAddr common.Address
+	id         uint64
+	once       sync.Once
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		for _, name := range s.eventNames {
+			s.repo.remove(name, s.clientAddr, s.id)
+		}
+		s.repo.notifyRemoved([]uint64{s.id})
+	})
+}
+
+// add subscribes one callback to several events, it keeps a single id so the events stay in order.
+func (er *EventRepository) add(eventNames []string, clientAddr common.Address, filter *Filter, cb EventCallback) *Subscription {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	er.nextID++
+	for _, eventName := range eventNames {
+		subscribeInfoMap := er.mapEventSubscribe[eventName]
+		if subscribeInfoMap == nil {
+			subscribeInfoMap = make(map[common.Address][]subscriber)
+			er.mapEventSubscribe[eventName] = subscribeInfoMap
+		}
+		s := subscriber{id: er.nextID, eventName: eventName, clientAddr: clientAddr, filter: filter, callback: cb}
+		subscribeInfoMap[clientAddr] = append(subscribeInfoMap[clientAddr], s)
+	}
+
+	return &Subscription{repo: er, eventNames: eventNames, clientAddr: clientAddr, id: er.nextID}
+}
+
+func (er *EventRepository) remove(eventName string, clientAddr common.Address, id uint64) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	subscribers := er.mapEventSubscribe[eventName][clientAddr]
+	for i, s := range subscribers {
+		if s.id == id {
+			// copy, so snapshots handed out before stay untouched
+			left := append(append([]subscriber{}, subscribers[:i]...), subscribers[i+1:]...)
+			er.set(eventName, clientAddr, left)
+			return
+		}
+	}
+}
+
+// removeAll drops every callback of the client for the event, false if there was none.
+func (er *EventRepository) removeAll(eventName string, clientAddr common.Address) bool {
+	er.mu.Lock()
+	subscribers := er.mapEventSubscribe[eventName][clientAddr]
+	if len(subscribers) == 0 {
+		er.mu.Unlock()
+		return false
+	}
+	er.set(eventName, clientAddr, nil)
+	var ids []uint64
+	for _, s := range subscribers {
+		if !er.has(s.id) {
+			ids = append(ids, s.id)
+		}
+	}
+	er.mu.Unlock()
+
+	er.notifyRemoved(ids)
+	return true
+}
+
+// has is called with er.mu held, a subscription of several events keeps its id in each of them.
+func (er *EventRepository) has(id uint64) bool {
+	for _, subscribeInfoMap := range er.mapEventSubscribe {
+		for _, subscribers := range subscribeInfoMap {
+			for _, s := range subscribers {
+				if s.id == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (er *EventRepository) notifyRemoved(ids []uint64) {
+	er.mu.RLock()
+	removed := er.removed
+	er.mu.RUnlock()
+	if removed != nil && len(ids) > 0 {
+		removed(ids)
+	}
+}
+
+// set is called with er.mu held.
+func (er *EventRepository) set(eventName string, clientAddr common.Address, subscribers []subscriber) {
+	subscribeInfoMap := er.mapEventSubscribe[eventName]
+	if len(subscribers) > 0 {
+		subscribeInfoMap[clientAddr] = subscribers
+		return
+	}
+	delete(subscribeInfoMap, clientAddr)
+	if len(subscribeInfoMap) == 0 {
+		delete(er.mapEventSubscribe, eventName)
+	}
+}
+
+// subscribers returns a copy of the subscribers of the event, it can be used without holding the lock.
+func (er *EventRepository) subscribers(eventName string) map[common.Address][]subscriber {
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	if er.mapEventSubscribe[eventName] == nil && er.mapEventSubscribe[anyEvent] == nil {
+		return nil
+	}
+	subs := make(map[common.Address][]subscriber)
+	for _, name := range []string{eventName, anyEvent} {
+		for addr, subscribers := range er.mapEventSubscribe[name] {
+			subs[addr] = append(subs[addr], subscribers...)
+		}
 	}
+	return subs
 }
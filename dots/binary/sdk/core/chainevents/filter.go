@@ -0,0 +1,181 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Filter selects the events of SubscribeFilter and SubscribeChannel.
+type Filter struct {
+	// Names of the events, empty for every event
+	Names []string
+	// Address the events are routed to by their users or owner field, like the client address of Subscribe
+	Address common.Address
+	// AnyAddress gets the events whatever users or owner they are routed to
+	AnyAddress bool
+	// Apps are the app seqNos to get events of, empty for the app of the engine
+	Apps []string
+	// AnyApp gets the events of every app
+	AnyApp bool
+	// Where must all hold for the decoded event
+	Where []Predicate
+	// Match is an optional check after Where
+	Match func(event events2.Event) bool
+}
+
+const (
+	OpEq = "=="
+	OpNe = "!="
+	OpGt = ">"
+	OpGe = ">="
+	OpLt = "<"
+	OpLe = "<="
+)
+
+// Predicate compares a field of the decoded event with Value, for example {"price", OpGt, 100}.
+// Integer fields compare as numbers, Value can be any go integer, *big.Int or a decimal string.
+// Address, hash and bool fields only support OpEq and OpNe.
+type Predicate struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+func (f *Filter) eventNames() []string {
+	if len(f.Names) == 0 {
+		return []string{anyEvent}
+	}
+	return f.Names
+}
+
+func (f *Filter) validate() error {
+	for _, p := range f.Where {
+		switch p.Op {
+		case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe:
+		default:
+			return fmt.Errorf("unknown operator %q of field %s", p.Op, p.Field)
+		}
+	}
+	return nil
+}
+
+func (f *Filter) acceptsApp(seqNo interface{}, appId string) bool {
+	if f.AnyApp {
+		return true
+	}
+	if len(f.Apps) == 0 {
+		return seqNo == appId
+	}
+	for _, app := range f.Apps {
+		if seqNo == app {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) matches(event events2.Event) bool {
+	for _, p := range f.Where {
+		if !p.match(event) {
+			return false
+		}
+	}
+	return f.Match == nil || f.Match(event)
+}
+
+func (p Predicate) match(event events2.Event) bool {
+	actual := event.Data.Get(p.Field)
+	if actual == nil {
+		return false
+	}
+
+	if a, ok := toBigInt(actual, false); ok {
+		want, ok := toBigInt(p.Value, true)
+		return ok && compared(a.Cmp(want), p.Op)
+	}
+	switch a := actual.(type) {
+	case string:
+		return compared(strings.Compare(a, fmt.Sprint(p.Value)), p.Op)
+	case common.Address:
+		want, ok := p.Value.(common.Address)
+		if !ok {
+			want = common.HexToAddress(fmt.Sprint(p.Value))
+		}
+		return equality(a == want, p.Op)
+	case common.Hash:
+		want, ok := p.Value.(common.Hash)
+		if !ok {
+			want = common.HexToHash(fmt.Sprint(p.Value))
+		}
+		return equality(a == want, p.Op)
+	default:
+		return equality(reflect.DeepEqual(actual, p.Value) || fmt.Sprint(actual) == fmt.Sprint(p.Value), p.Op)
+	}
+}
+
+func compared(cmp int, op string) bool {
+	switch op {
+	case OpEq:
+		return cmp == 0
+	case OpNe:
+		return cmp != 0
+	case OpGt:
+		return cmp > 0
+	case OpGe:
+		return cmp >= 0
+	case OpLt:
+		return cmp < 0
+	case OpLe:
+		return cmp <= 0
+	}
+	return false
+}
+
+func equality(equal bool, op string) bool {
+	switch op {
+	case OpEq:
+		return equal
+	case OpNe:
+		return !equal
+	}
+	return false
+}
+
+func toBigInt(v interface{}, fromString bool) (*big.Int, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return n, n != nil
+	case string:
+		if !fromString {
+			return nil, false
+		}
+		return new(big.Int).SetString(n, 10)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+// SubscribeFilter calls cb for every event the filter selects.
+func (e *EventEngine) SubscribeFilter(filter Filter, cb EventCallback) (*Subscription, error) {
+	if cb == nil {
+		return nil, errors.New("couldn't subscribe event because of null eventCallback")
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return e.repo.add(filter.eventNames(), filter.Address, &filter, cb), nil
+}
@@ -0,0 +1,175 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	settings2 "github.com/scryinfo/dp/dots/binary/sdk/settings"
+	"math/big"
+	"testing"
+)
+
+func publishEvent(seqNo string, price int64, publishId string, users ...common.Address) events2.Event {
+	event := events2.Event{Name: "DataPublish", Data: events2.NewJSONObj()}
+	event.Data.Set(APP_SEQ_NO, seqNo)
+	event.Data.Set("price", big.NewInt(price))
+	event.Data.Set("publishId", publishId)
+	event.Data.Set(TARGET_USERS, users)
+	return event
+}
+
+func TestFilterWildcards(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	other := common.HexToAddress("0x01")
+
+	count := make(map[string]int)
+	subscribeCounter := func(name string, filter Filter) {
+		if _, err := engine.SubscribeFilter(filter, func(event events2.Event) bool {
+			count[name]++
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscribeCounter("plain", Filter{Names: []string{"DataPublish"}, Address: chanAddr})
+	subscribeCounter("anyAddress", Filter{Names: []string{"DataPublish"}, AnyAddress: true})
+	subscribeCounter("anyApp", Filter{Address: chanAddr, AnyApp: true})
+	subscribeCounter("everything", Filter{AnyAddress: true, AnyApp: true})
+
+	engine.executeEvent(publishEvent("app", 1, "a", chanAddr))
+	engine.executeEvent(publishEvent("app", 1, "a", other))
+	engine.executeEvent(publishEvent("otherApp", 1, "a", chanAddr))
+	engine.executeEvent(publishEvent("otherApp", 1, "a", other))
+
+	want := map[string]int{"plain": 1, "anyAddress": 2, "anyApp": 2, "everything": 4}
+	for name, n := range want {
+		if count[name] != n {
+			t.Errorf("%s subscription got %d events, want %d", name, count[name], n)
+		}
+	}
+}
+
+func TestFilterPredicates(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+
+	var got []string
+	_, err := engine.SubscribeFilter(Filter{
+		Names:      []string{"DataPublish"},
+		AnyAddress: true,
+		Where: []Predicate{
+			{Field: "price", Op: OpGt, Value: 100},
+			{Field: "publishId", Op: OpNe, Value: "skip"},
+		},
+	}, func(event events2.Event) bool {
+		got = append(got, event.Data.Get("publishId").(string))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine.executeEvent(publishEvent("app", 100, "cheap", chanAddr))
+	engine.executeEvent(publishEvent("app", 101, "skip", chanAddr))
+	engine.executeEvent(publishEvent("app", 200, "wanted", chanAddr))
+	if len(got) != 1 || got[0] != "wanted" {
+		t.Errorf("want only the wanted event, got %v", got)
+	}
+
+	if _, err = engine.SubscribeFilter(Filter{Where: []Predicate{{Field: "price", Op: "~", Value: 1}}}, callback); err == nil {
+		t.Error("want error for an unknown operator")
+	}
+	_, sub := engine.SubscribeChannel(context.Background(), Filter{Where: []Predicate{{Field: "price", Op: "~"}}}, ChannelConfig{})
+	if err := <-sub.Err(); err == nil {
+		t.Error("want channel subscription ended by an unknown operator")
+	}
+}
+
+func TestPredicateTypes(t *testing.T) {
+	event := events2.Event{Data: events2.NewJSONObj()}
+	event.Data.Set("seller", chanAddr)
+	event.Data.Set("state", uint8(2))
+	event.Data.Set("truth", true)
+
+	cases := []struct {
+		p    Predicate
+		want bool
+	}{
+		{Predicate{"seller", OpEq, chanAddr.Hex()}, true},
+		{Predicate{"seller", OpNe, chanAddr}, false},
+		{Predicate{"seller", OpGt, chanAddr}, false},
+		{Predicate{"state", OpLe, "2"}, true},
+		{Predicate{"state", OpLt, big.NewInt(2)}, false},
+		{Predicate{"truth", OpEq, true}, true},
+		{Predicate{"missing", OpEq, 1}, false},
+	}
+	for _, c := range cases {
+		if got := c.p.match(event); got != c.want {
+			t.Errorf("%+v: got %v, want %v", c.p, got, c.want)
+		}
+	}
+}
+
+func TestRoutingByClientApps(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	brandA := common.HexToAddress("0x0a")
+	brandB := common.HexToAddress("0x0b")
+
+	got := make(map[common.Address][]string)
+	for addr, apps := range map[common.Address][]string{brandA: {"shopA"}, brandB: {"shopB", "app"}} {
+		addr := addr
+		if _, err := engine.SubscribeFilter(Filter{Names: []string{"DataPublish"}, Address: addr, Apps: apps}, func(event events2.Event) bool {
+			got[addr] = append(got[addr], event.Data.Get(APP_SEQ_NO).(string))
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, seqNo := range []string{"shopA", "shopB", "app"} {
+		engine.executeEvent(publishEvent(seqNo, 1, "p", common.HexToAddress(BROADCAST_TO_USERS)))
+	}
+	if len(got[brandA]) != 1 || got[brandA][0] != "shopA" {
+		t.Errorf("client of shopA got %v", got[brandA])
+	}
+	if len(got[brandB]) != 2 {
+		t.Errorf("client of shopB and app got %v", got[brandB])
+	}
+}
+
+func TestEnginesDispatchTheirOwnApp(t *testing.T) {
+	settings2.SetAppId("process")
+	got := make(map[string][]string)
+	engines := make(map[string]*EventEngine)
+	for _, app := range []string{"shopA", "shopB"} {
+		app := app
+		engine := NewEventEngine(nil, nil, nil, false, app)
+		record := func(event events2.Event) bool {
+			got[app] = append(got[app], event.Data.Get(APP_SEQ_NO).(string))
+			return true
+		}
+		if _, err := engine.Subscribe(chanAddr, "DataPublish", record); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := engine.SubscribeFilter(Filter{Names: []string{"DataPublish"}, Address: chanAddr}, record); err != nil {
+			t.Fatal(err)
+		}
+		engines[app] = engine
+	}
+
+	for _, engine := range engines {
+		for _, seqNo := range []string{"shopA", "shopB", "process"} {
+			engine.executeEvent(publishEvent(seqNo, 1, "p", chanAddr))
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("want events for both engines, got %v", got)
+	}
+	for app, seqNos := range got {
+		if len(seqNos) != 2 || seqNos[0] != app || seqNos[1] != app {
+			t.Errorf("engine of %s got the events of %v", app, seqNos)
+		}
+	}
+}
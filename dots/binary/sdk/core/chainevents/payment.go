@@ -0,0 +1,41 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+)
+
+// Payment is a token transfer to the subscribed address.
+type Payment struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Event events2.Event
+}
+
+type PaymentCallback func(payment Payment) bool
+
+// SubscribeIncomingPayments calls cb for every token transfer that arrives at clientAddr,
+// the transfers it sends are left out.
+func (e *EventEngine) SubscribeIncomingPayments(clientAddr common.Address, cb PaymentCallback) (*Subscription, error) {
+	if cb == nil {
+		return nil, errors.New("couldn't subscribe incoming payments because of null callback")
+	}
+	filter := Filter{
+		Names:   []string{TOKEN_EVT_TRANSFER},
+		Address: clientAddr,
+		Where:   []Predicate{{Field: TARGET_TO, Op: OpEq, Value: clientAddr}},
+	}
+	return e.SubscribeFilter(filter, func(event events2.Event) bool {
+		transfer, ok := event.Typed.(*events2.Transfer)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(Payment{From: transfer.From, To: transfer.To, Value: transfer.Value, Event: event})
+	})
+}
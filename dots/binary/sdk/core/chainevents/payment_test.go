@@ -0,0 +1,54 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"math/big"
+	"testing"
+)
+
+func transferEvent(from, to common.Address, value int64) events2.Event {
+	event := events2.Event{Name: TOKEN_EVT_TRANSFER, Data: events2.NewJSONObj()}
+	event.Data.Set(TARGET_FROM, from)
+	event.Data.Set(TARGET_TO, to)
+	event.Data.Set("value", big.NewInt(value))
+	event.Typed = &events2.Transfer{From: from, To: to, Value: big.NewInt(value)}
+	return event
+}
+
+func TestTransferRoutedToBothSides(t *testing.T) {
+	engine := NewEventEngine(nil, nil, nil, false, "app")
+	alice := common.HexToAddress("0x0a")
+	bob := common.HexToAddress("0x0b")
+	carol := common.HexToAddress("0x0c")
+
+	got := make(map[common.Address]int)
+	for _, addr := range []common.Address{alice, bob, carol} {
+		addr := addr
+		engine.Subscribe(addr, TOKEN_EVT_TRANSFER, func(event events2.Event) bool {
+			got[addr]++
+			return true
+		})
+	}
+
+	var payments []Payment
+	if _, err := engine.SubscribeIncomingPayments(bob, func(p Payment) bool {
+		payments = append(payments, p)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.executeEvent(transferEvent(alice, bob, 5))
+	engine.executeEvent(transferEvent(bob, alice, 2))
+
+	if got[alice] != 2 || got[bob] != 2 || got[carol] != 0 {
+		t.Errorf("unexpected routing %v", got)
+	}
+	if len(payments) != 1 || payments[0].From != alice || payments[0].Value.Int64() != 5 {
+		t.Errorf("want one incoming payment from alice, got %+v", payments)
+	}
+}
@@ -0,0 +1,86 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"time"
+)
+
+// weight of the newest pass in the smoothed events per second
+const rateSmoothing = 0.3
+
+type SyncProgress struct {
+	Current         uint64
+	Head            uint64
+	Lag             uint64
+	EventsPerSecond float64
+}
+
+type ProgressCallback func(progress SyncProgress)
+
+// SubscribeProgress calls cb after every scanned range, cancel stops the calls.
+func (e *EventEngine) SubscribeProgress(cb ProgressCallback) (cancel func()) {
+	e.progressMu.Lock()
+	defer e.progressMu.Unlock()
+
+	e.progressSeq++
+	id := e.progressSeq
+	e.progressCallbacks[id] = cb
+
+	return func() {
+		e.progressMu.Lock()
+		defer e.progressMu.Unlock()
+		delete(e.progressCallbacks, id)
+	}
+}
+
+// Progress returns the latest sync progress.
+func (e *EventEngine) Progress() SyncProgress {
+	e.progressMu.Lock()
+	defer e.progressMu.Unlock()
+	return e.progress
+}
+
+func (e *EventEngine) trackProgress(progressChannel <-chan events2.Progress, quit <-chan struct{}) {
+	last := time.Now()
+	for {
+		select {
+		case p := <-progressChannel:
+			now := time.Now()
+			e.updateProgress(p, now.Sub(last))
+			last = now
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (e *EventEngine) updateProgress(p events2.Progress, elapsed time.Duration) {
+	e.resetFailures()
+
+	e.progressMu.Lock()
+	progress := SyncProgress{
+		Current:         p.To,
+		Head:            p.Head,
+		EventsPerSecond: e.progress.EventsPerSecond,
+	}
+	if p.Head > p.To {
+		progress.Lag = p.Head - p.To
+	}
+	if elapsed > 0 {
+		rate := float64(p.Events) / elapsed.Seconds()
+		progress.EventsPerSecond = rateSmoothing*rate + (1-rateSmoothing)*progress.EventsPerSecond
+	}
+	e.progress = progress
+	callbacks := make([]ProgressCallback, 0, len(e.progressCallbacks))
+	for _, cb := range e.progressCallbacks {
+		callbacks = append(callbacks, cb)
+	}
+	e.progressMu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(progress)
+	}
+}
@@ -0,0 +1,87 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"github.com/scryinfo/dot/dot"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+	"go.uber.org/zap"
+)
+
+// rpc failures in a row without a scanned range in between that make a persistent failure
+const persistentFailureNum = 5
+
+type ScanFailure struct {
+	*events2.ScanError
+	// Consecutive counts the rpc failures since the last scanned range
+	Consecutive int
+	// Persistent is set once the node kept failing, events are not delivered until it recovers
+	Persistent bool
+}
+
+type ErrorCallback func(failure ScanFailure)
+
+// SubscribeErrors calls cb for every scanner error, cancel stops the calls.
+func (e *EventEngine) SubscribeErrors(cb ErrorCallback) (cancel func()) {
+	e.errorMu.Lock()
+	defer e.errorMu.Unlock()
+
+	e.errorSeq++
+	id := e.errorSeq
+	e.errorCallbacks[id] = cb
+
+	return func() {
+		e.errorMu.Lock()
+		defer e.errorMu.Unlock()
+		delete(e.errorCallbacks, id)
+	}
+}
+
+func (e *EventEngine) trackErrors(errorChannel <-chan error, quit <-chan struct{}) {
+	for {
+		select {
+		case err := <-errorChannel:
+			se, ok := err.(*events2.ScanError)
+			if !ok {
+				se = &events2.ScanError{Kind: events2.ErrorRPC, Err: err}
+			}
+			e.handleError(se)
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (e *EventEngine) handleError(se *events2.ScanError) {
+	e.errorMu.Lock()
+	if se.Kind == events2.ErrorRPC {
+		e.failures += 1 + se.Dropped
+	}
+	failure := ScanFailure{
+		ScanError:   se,
+		Consecutive: e.failures,
+		Persistent:  e.failures >= persistentFailureNum,
+	}
+	callbacks := make([]ErrorCallback, 0, len(e.errorCallbacks))
+	for _, cb := range e.errorCallbacks {
+		callbacks = append(callbacks, cb)
+	}
+	e.errorMu.Unlock()
+
+	if failure.Persistent {
+		dot.Logger().Errorln("event scanner keeps failing", zap.Int("consecutive", failure.Consecutive), zap.Error(se))
+	} else {
+		dot.Logger().Warnln("event scanner error", zap.Error(se))
+	}
+	for _, cb := range callbacks {
+		cb(failure)
+	}
+}
+
+// resetFailures is called after every scanned range.
+func (e *EventEngine) resetFailures() {
+	e.errorMu.Lock()
+	defer e.errorMu.Unlock()
+	e.failures = 0
+}
@@ -0,0 +1,127 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainevents
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/scryinfo/dot/dot"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
+)
+
+// typed subscriptions, the callback gets the decoded struct besides the raw event.
+
+func typedMismatch(event events2.Event) bool {
+	dot.Logger().Errorln("unexpected typed data of event:" + event.String())
+	return false
+}
+
+func (e *EventEngine) SubscribeDataPublish(clientAddr common.Address, cb func(events2.Event, *events2.DataPublish) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "DataPublish", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.DataPublish)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeTransactionCreate(clientAddr common.Address, cb func(events2.Event, *events2.TransactionCreate) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "TransactionCreate", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.TransactionCreate)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeVerifiersChosen(clientAddr common.Address, cb func(events2.Event, *events2.VerifiersChosen) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "VerifiersChosen", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.VerifiersChosen)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeVote(clientAddr common.Address, cb func(events2.Event, *events2.Vote) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "Vote", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.Vote)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeBuy(clientAddr common.Address, cb func(events2.Event, *events2.Buy) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "Buy", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.Buy)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeReadyForDownload(clientAddr common.Address, cb func(events2.Event, *events2.ReadyForDownload) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "ReadyForDownload", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.ReadyForDownload)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeTransactionClose(clientAddr common.Address, cb func(events2.Event, *events2.TransactionClose) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "TransactionClose", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.TransactionClose)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeRegisterVerifier(clientAddr common.Address, cb func(events2.Event, *events2.RegisterVerifier) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "RegisterVerifier", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.RegisterVerifier)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeVerifierDisable(clientAddr common.Address, cb func(events2.Event, *events2.VerifierDisable) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "VerifierDisable", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.VerifierDisable)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeApproval(clientAddr common.Address, cb func(events2.Event, *events2.Approval) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "Approval", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.Approval)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
+
+func (e *EventEngine) SubscribeTransfer(clientAddr common.Address, cb func(events2.Event, *events2.Transfer) bool) (*Subscription, error) {
+	return e.Subscribe(clientAddr, "Transfer", func(event events2.Event) bool {
+		typed, ok := event.Typed.(*events2.Transfer)
+		if !ok {
+			return typedMismatch(event)
+		}
+		return cb(event, typed)
+	})
+}
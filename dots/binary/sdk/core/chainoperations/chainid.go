@@ -0,0 +1,42 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// ChainIDBackend is the rpc client, ethclient has no eth_chainId.
+type ChainIDBackend interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// NetworkIDBackend is the part of ethclient.Client asked when the node does not know eth_chainId.
+type NetworkIDBackend interface {
+	NetworkID(ctx context.Context) (*big.Int, error)
+}
+
+// ErrNoChainID is returned instead of signing without replay protection.
+var ErrNoChainID = errors.New("no chain id to sign the transaction for")
+
+// ReadChainID asks the node by eth_chainId, older nodes answer by the network id, equal on the public networks.
+func ReadChainID(ctx context.Context, rc ChainIDBackend, conn NetworkIDBackend) (*big.Int, error) {
+	var id hexutil.Big
+	if err := rc.CallContext(ctx, &id, "eth_chainId"); err == nil {
+		return (*big.Int)(&id), nil
+	}
+	return conn.NetworkID(ctx)
+}
+
+// Signer is the EIP155 signer of the chain of the transactor.
+func (t *Transactor) Signer() (types.Signer, error) {
+	if t.signer == nil {
+		return nil, ErrNoChainID
+	}
+	return t.signer, nil
+}
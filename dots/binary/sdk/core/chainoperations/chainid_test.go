@@ -0,0 +1,74 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+type fakeChainID struct {
+	chainID   *big.Int
+	networkID *big.Int
+}
+
+func (fc fakeChainID) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if method != "eth_chainId" || fc.chainID == nil {
+		return errors.New("the method eth_chainId does not exist/is not available")
+	}
+	*result.(*hexutil.Big) = hexutil.Big(*fc.chainID)
+	return nil
+}
+
+func (fc fakeChainID) NetworkID(ctx context.Context) (*big.Int, error) {
+	return fc.networkID, nil
+}
+
+func TestReadChainID(t *testing.T) {
+	fc := fakeChainID{chainID: big.NewInt(1337), networkID: big.NewInt(5777)}
+	if id, err := ReadChainID(context.Background(), fc, fc); err != nil || id.Int64() != 1337 {
+		t.Errorf("expect chain id 1337, got %v %v", id, err)
+	}
+
+	fc.chainID = nil
+	if id, err := ReadChainID(context.Background(), fc, fc); err != nil || id.Int64() != 5777 {
+		t.Errorf("expect the network id 5777 of an old node, got %v %v", id, err)
+	}
+}
+
+func TestSignForChainID(t *testing.T) {
+	if _, err := NewTransactor(nil, nil).Signer(); err != ErrNoChainID {
+		t.Fatalf("expect ErrNoChainID instead of a signer without replay protection, got %v", err)
+	}
+	signer, err := NewTransactor(nil, big.NewInt(1337)).Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signed.Protected() || signed.ChainId().Int64() != 1337 {
+		t.Errorf("expect a replay protected transaction of chain 1337, got chain %v", signed.ChainId())
+	}
+	from, err := types.Sender(types.NewEIP155Signer(big.NewInt(1337)), signed)
+	if err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("expect the sender recovered for chain 1337, got %s %v", from.Hex(), err)
+	}
+	if _, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), signed); err == nil {
+		t.Error("expect the transaction refused on another chain")
+	}
+}
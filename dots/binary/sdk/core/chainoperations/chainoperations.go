@@ -8,24 +8,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/scryinfo/dot/dot"
 	accounts2 "github.com/scryinfo/dp/dots/binary/sdk/util/accounts"
+	"github.com/scryinfo/dp/dots/eth/nonce"
+	"go.uber.org/zap"
 	"math/big"
 	"strings"
 )
 
-var (
-	GAS_LIMIT uint64 = 21000
-)
-
 type TransactParams struct {
 	From     common.Address
 	Password string
 	Value    *big.Int
 	Pending  bool
+	// AppId is the seqNo stamped on protocol transactions, empty for the app of the chain wrapper
+	AppId string
+	// GasLimit of the transaction, 0 estimates it and adds the margin of the gas config
+	GasLimit uint64
 }
 
 func DecodeKeystoreAddress(keyJsonStr []byte) string {
@@ -41,23 +45,89 @@ func DecodeKeystoreAddress(keyJsonStr []byte) string {
 	return addr.Address
 }
 
-func BuildTransactOpts(txParams *TransactParams) *bind.TransactOpts {
+// Transactor sends the transactions of one network, the nonces of its accounts are its own,
+// they are signed for its chain id and priced by its gas strategy.
+type Transactor struct {
+	nonces *nonce.Manager
+	signer types.Signer
+	gas    *gasSettings
+}
+
+// NewTransactor signs for chainID by EIP155, without a chain id every transaction fails with ErrNoChainID.
+func NewTransactor(nonces *nonce.Manager, chainID *big.Int) *Transactor {
+	t := &Transactor{nonces: nonces, gas: &gasSettings{limitMargin: defaultGasLimitMargin}}
+	if chainID != nil {
+		t.signer = types.NewEIP155Signer(chainID)
+	}
+	return t
+}
+
+func (t *Transactor) Nonces() *nonce.Manager {
+	return t.nonces
+}
+
+// BuildTransactOpts takes the next nonce of the sender from the nonce manager,
+// it must be given back by ReleaseNonce, Transact does both. The gas price comes from the gas strategy.
+func (t *Transactor) BuildTransactOpts(txParams *TransactParams) *bind.TransactOpts {
 	opts := &bind.TransactOpts{
 		From:  txParams.From,
 		Nonce: nil,
-		Signer: func(signer types.Signer, address common.Address,
+		// bind passes the homestead signer, the transaction is signed for the chain instead
+		Signer: func(_ types.Signer, address common.Address,
 			transaction *types.Transaction) (*types.Transaction, error) {
-			return SignTransaction(signer, address, transaction, txParams.Password)
+			// bind estimated the gas limit, the margin covers state changing before the transaction is mined
+			if txParams.GasLimit == 0 {
+				transaction = t.withGasMargin(transaction)
+			}
+			// the transaction is not sent when it could not be signed, its nonce is free again
+			signer, err := t.Signer()
+			if err != nil {
+				return nil, nonce.NotSent(err)
+			}
+			signed, err := SignTransaction(signer, address, transaction, txParams.Password)
+			return signed, nonce.NotSent(err)
 		},
 		Value:    txParams.Value,
-		GasPrice: big.NewInt(0),
-		GasLimit: 3000000,
+		GasLimit: txParams.GasLimit,
 		Context:  context.Background(),
 	}
+	if price, err := t.SuggestGasPrice(opts.Context); err == nil {
+		opts.GasPrice = price
+	} else {
+		dot.Logger().Warnln("", zap.NamedError("failed to get gas price from the gas strategy, error:", err))
+	}
+	if t.nonces != nil {
+		// without a nonce bind falls back to the pending nonce of the node
+		if n, err := t.nonces.Next(opts.Context, opts.From); err == nil {
+			opts.Nonce = new(big.Int).SetUint64(n)
+		} else {
+			dot.Logger().Warnln("", zap.NamedError("failed to get local nonce, error:", err))
+		}
+	}
 
 	return opts
 }
 
+// ReleaseNonce gives the nonce of opts back to the nonce manager with the result of sending the transaction.
+func (t *Transactor) ReleaseNonce(opts *bind.TransactOpts, err error) {
+	if opts.Nonce != nil && t.nonces != nil {
+		t.nonces.Done(opts.From, opts.Nonce.Uint64(), err)
+	}
+}
+
+// Transact sends the transaction of send with a local nonce, a nonce refused by the node
+// makes the manager read the chain again and the transaction is sent once more.
+func (t *Transactor) Transact(txParams *TransactParams, send func(opts *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	for retried := false; ; retried = true {
+		opts := t.BuildTransactOpts(txParams)
+		tx, err := send(opts)
+		t.ReleaseNonce(opts, err)
+		if err == nil || retried || !nonce.IsNonceError(err) {
+			return tx, err
+		}
+	}
+}
+
 func SignTransaction(signer types.Signer, address common.Address,
 	transaction *types.Transaction, password string) (*types.Transaction, error) {
 	h := signer.Hash(transaction)
@@ -84,19 +154,18 @@ func BuildCallOpts(txParams *TransactParams) *bind.CallOpts {
 	return opts
 }
 
-func TransferEth(from common.Address,
+func (t *Transactor) TransferEth(from common.Address,
 	password string,
 	to common.Address,
 	value *big.Int,
 	client *ethclient.Client) (*types.Transaction, error) {
-	txParam := &TransactParams{from, password, value, false}
-	opts := BuildTransactOpts(txParam)
-	tx, err := transact(opts, to, client)
-
-	return tx, err
+	txParam := &TransactParams{From: from, Password: password, Value: value}
+	return t.Transact(txParam, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return t.transact(opts, to, client)
+	})
 }
 
-func transact(opts *bind.TransactOpts, to common.Address, client *ethclient.Client) (*types.Transaction, error) {
+func (t *Transactor) transact(opts *bind.TransactOpts, to common.Address, client *ethclient.Client) (*types.Transaction, error) {
 	var err error
 
 	// Ensure a valid value field and resolve the account nonce
@@ -123,7 +192,10 @@ func transact(opts *bind.TransactOpts, to common.Address, client *ethclient.Clie
 	}
 	gasLimit := opts.GasLimit
 	if gasLimit == 0 {
-		gasLimit = GAS_LIMIT
+		gasLimit, err = client.EstimateGas(opts.Context, ethereum.CallMsg{From: opts.From, To: &to, GasPrice: gasPrice, Value: value})
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		}
 	}
 
 	// Create the transaction, sign it and schedule it for execution
@@ -133,7 +205,11 @@ func transact(opts *bind.TransactOpts, to common.Address, client *ethclient.Clie
 		return nil, errors.New("no signer to authorize the transaction with")
 	}
 
-	signedTx, err := opts.Signer(types.HomesteadSigner{}, opts.From, rawTx)
+	signer, err := t.Signer()
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := opts.Signer(signer, opts.From, rawTx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,35 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/scryinfo/dp/dots/eth/nonce"
+	"math/big"
+	"testing"
+)
+
+type fakeNonces uint64
+
+func (fn fakeNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return uint64(fn), nil
+}
+
+func TestTransactorsKeepTheirNonces(t *testing.T) {
+	txParams := &TransactParams{From: common.Address{1}}
+	first := NewTransactor(nonce.NewManager(fakeNonces(5)), big.NewInt(1))
+	second := NewTransactor(nonce.NewManager(fakeNonces(100)), big.NewInt(2))
+
+	for want := uint64(5); want < 8; want++ {
+		opts := first.BuildTransactOpts(txParams)
+		if opts.Nonce == nil || opts.Nonce.Uint64() != want {
+			t.Fatalf("expect nonce %d of the first network, got %v", want, opts.Nonce)
+		}
+		first.ReleaseNonce(opts, nil)
+	}
+	if opts := second.BuildTransactOpts(txParams); opts.Nonce == nil || opts.Nonce.Uint64() != 100 {
+		t.Errorf("expect the second network to start at its own nonce 100, got %v", opts.Nonce)
+	}
+}
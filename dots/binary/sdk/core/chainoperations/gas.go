@@ -0,0 +1,230 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+const (
+	GasNode       = "node"
+	GasFixed      = "fixed"
+	GasPercentile = "percentile"
+
+	defaultGasLimitMargin = 0.2
+	defaultGasBlocks      = 20
+	defaultGasPercentile  = 60
+)
+
+// GasBackend is the part of ethclient.Client the gas strategies read from.
+type GasBackend interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+}
+
+// GasStrategy prices the transactions, the multiplier and the cap of the gas config are applied afterwards.
+type GasStrategy interface {
+	GasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// NodeGasPrice asks the node for its suggestion.
+type NodeGasPrice struct {
+	Backend GasBackend
+}
+
+func (ng NodeGasPrice) GasPrice(ctx context.Context) (*big.Int, error) {
+	return ng.Backend.SuggestGasPrice(ctx)
+}
+
+// FixedGasPrice always uses Price, e.g. 0 on a private chain without gas fees.
+// Without Price it fails, so the transactions fall back to the price of the node.
+type FixedGasPrice struct {
+	Price *big.Int
+}
+
+func (fg FixedGasPrice) GasPrice(ctx context.Context) (*big.Int, error) {
+	if fg.Price == nil {
+		return nil, errors.New("fixed gas price is not set")
+	}
+	return new(big.Int).Set(fg.Price), nil
+}
+
+// PercentileGasPrice takes the Percentile of the gas prices paid in the last Blocks blocks,
+// the node suggestion is used while the blocks are empty. The price is kept until the next head block.
+type PercentileGasPrice struct {
+	Backend    GasBackend
+	Blocks     int
+	Percentile int
+
+	head  common.Hash
+	price *big.Int
+	mu    sync.Mutex
+}
+
+func (pg *PercentileGasPrice) GasPrice(ctx context.Context) (*big.Int, error) {
+	header, err := pg.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	pg.mu.Lock()
+	if pg.price != nil && pg.head == header.Hash() {
+		defer pg.mu.Unlock()
+		return new(big.Int).Set(pg.price), nil
+	}
+	pg.mu.Unlock()
+
+	price, err := pg.percentile(ctx, header.Number)
+	if err != nil {
+		return nil, err
+	}
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.head, pg.price = header.Hash(), price
+	return new(big.Int).Set(price), nil
+}
+
+func (pg *PercentileGasPrice) percentile(ctx context.Context, number *big.Int) (*big.Int, error) {
+	head, err := pg.Backend.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	var prices []*big.Int
+	for block, i := head, 0; ; i++ {
+		for _, tx := range block.Transactions() {
+			prices = append(prices, tx.GasPrice())
+		}
+		if i+1 >= pg.Blocks || block.NumberU64() == 0 {
+			break
+		}
+		if block, err = pg.Backend.BlockByNumber(ctx, new(big.Int).SetUint64(block.NumberU64()-1)); err != nil {
+			return nil, err
+		}
+	}
+	if len(prices) == 0 {
+		return pg.Backend.SuggestGasPrice(ctx)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	return prices[(len(prices)-1)*pg.Percentile/100], nil
+}
+
+// GasConfig is read from the app config, prices are in wei.
+type GasConfig struct {
+	// Strategy is GasNode, GasFixed or GasPercentile, empty for GasNode
+	Strategy   string
+	Price      string
+	Blocks     int
+	Percentile int
+	// Multiplier scales the strategy price, 0 for 1
+	Multiplier float64
+	// PriceCap limits the price after the multiplier, empty for no limit
+	PriceCap string
+	// LimitMargin is added to the estimated gas limit, 0.2 adds 20%
+	LimitMargin float64
+}
+
+type gasSettings struct {
+	strategy    GasStrategy
+	multiplier  float64
+	cap         *big.Int
+	limitMargin float64
+	mu          sync.Mutex
+}
+
+// ConfigureGas prices the transactions of t by the strategy of conf reading from the backend of its network,
+// without it bind asks the node.
+func (t *Transactor) ConfigureGas(conf GasConfig, backend GasBackend) error {
+	var strategy GasStrategy
+	switch conf.Strategy {
+	case "", GasNode:
+		strategy = NodeGasPrice{Backend: backend}
+	case GasFixed:
+		price, ok := new(big.Int).SetString(conf.Price, 10)
+		if !ok {
+			return fmt.Errorf("invalid fixed gas price %q", conf.Price)
+		}
+		strategy = FixedGasPrice{Price: price}
+	case GasPercentile:
+		pg := &PercentileGasPrice{Backend: backend, Blocks: conf.Blocks, Percentile: conf.Percentile}
+		if pg.Blocks <= 0 {
+			pg.Blocks = defaultGasBlocks
+		}
+		if pg.Percentile <= 0 || pg.Percentile > 100 {
+			pg.Percentile = defaultGasPercentile
+		}
+		strategy = pg
+	default:
+		return fmt.Errorf("unknown gas strategy %q", conf.Strategy)
+	}
+
+	var priceCap *big.Int
+	if conf.PriceCap != "" {
+		var ok bool
+		if priceCap, ok = new(big.Int).SetString(conf.PriceCap, 10); !ok {
+			return fmt.Errorf("invalid gas price cap %q", conf.PriceCap)
+		}
+	}
+	if conf.Multiplier < 0 || conf.LimitMargin < 0 {
+		return errors.New("gas multiplier and limit margin should not be negative")
+	}
+	margin := conf.LimitMargin
+	if margin == 0 {
+		margin = defaultGasLimitMargin
+	}
+
+	t.SetGasStrategy(strategy, conf.Multiplier, priceCap)
+	t.gas.mu.Lock()
+	defer t.gas.mu.Unlock()
+	t.gas.limitMargin = margin
+	return nil
+}
+
+// SetGasStrategy replaces the strategy, multiplier 0 keeps the price and a nil cap does not limit it.
+func (t *Transactor) SetGasStrategy(strategy GasStrategy, multiplier float64, priceCap *big.Int) {
+	t.gas.mu.Lock()
+	defer t.gas.mu.Unlock()
+	t.gas.strategy, t.gas.multiplier, t.gas.cap = strategy, multiplier, priceCap
+}
+
+// SuggestGasPrice returns nil without a strategy, bind then asks the node itself.
+func (t *Transactor) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	t.gas.mu.Lock()
+	strategy, multiplier, priceCap := t.gas.strategy, t.gas.multiplier, t.gas.cap
+	t.gas.mu.Unlock()
+	if strategy == nil {
+		return nil, nil
+	}
+
+	price, err := strategy.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if multiplier > 0 && multiplier != 1 {
+		price, _ = new(big.Float).Mul(new(big.Float).SetInt(price), big.NewFloat(multiplier)).Int(nil)
+	}
+	if priceCap != nil && price.Cmp(priceCap) > 0 {
+		price = new(big.Int).Set(priceCap)
+	}
+	return price, nil
+}
+
+// withGasMargin raises the estimated gas limit of tx by the margin, the gas not used is not paid.
+func (t *Transactor) withGasMargin(tx *types.Transaction) *types.Transaction {
+	t.gas.mu.Lock()
+	margin := t.gas.limitMargin
+	t.gas.mu.Unlock()
+
+	limit := tx.Gas() + uint64(float64(tx.Gas())*margin)
+	if tx.To() == nil {
+		return types.NewContractCreation(tx.Nonce(), tx.Value(), limit, tx.GasPrice(), tx.Data())
+	}
+	return types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), limit, tx.GasPrice(), tx.Data())
+}
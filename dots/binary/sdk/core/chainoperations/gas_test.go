@@ -0,0 +1,129 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+)
+
+type fakeGasBackend struct {
+	blocks  []*types.Block
+	suggest *big.Int
+	reads   int
+}
+
+func newFakeGasBackend(prices ...[]int64) *fakeGasBackend {
+	fb := &fakeGasBackend{suggest: big.NewInt(7)}
+	for i, block := range prices {
+		var txs []*types.Transaction
+		for j, price := range block {
+			txs = append(txs, types.NewTransaction(uint64(j), common.Address{}, nil, 21000, big.NewInt(price), nil))
+		}
+		fb.blocks = append(fb.blocks, types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, txs, nil, nil))
+	}
+	return fb
+}
+
+func (fb *fakeGasBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return fb.suggest, nil
+}
+
+func (fb *fakeGasBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	block, err := fb.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (fb *fakeGasBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	fb.reads++
+	if number == nil {
+		return fb.blocks[len(fb.blocks)-1], nil
+	}
+	return fb.blocks[number.Int64()], nil
+}
+
+func TestPercentileGasPrice(t *testing.T) {
+	fb := newFakeGasBackend([]int64{100, 100}, []int64{1, 2, 3}, []int64{4, 5})
+	pg := &PercentileGasPrice{Backend: fb, Blocks: 2, Percentile: 50}
+	price, err := pg.GasPrice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Int64() != 3 {
+		t.Errorf("expect the median 3 of the last two blocks, got %v", price)
+	}
+
+	// the same head block keeps the price, a new one reads the blocks again
+	fb.reads = 0
+	if price, _ := pg.GasPrice(context.Background()); price.Int64() != 3 || fb.reads != 1 {
+		t.Errorf("expect the cached price with only the head read, got %v after %d reads", price, fb.reads)
+	}
+	fb.blocks = append(fb.blocks, types.NewBlock(&types.Header{Number: big.NewInt(3)}, []*types.Transaction{
+		types.NewTransaction(0, common.Address{}, nil, 21000, big.NewInt(9), nil)}, nil, nil))
+	if price, _ := pg.GasPrice(context.Background()); price.Int64() != 5 {
+		t.Errorf("expect the median 5 of the new last two blocks, got %v", price)
+	}
+
+	empty := &PercentileGasPrice{Backend: newFakeGasBackend(nil, nil), Blocks: 5, Percentile: 50}
+	if price, _ := empty.GasPrice(context.Background()); price.Int64() != 7 {
+		t.Errorf("expect the node suggestion for empty blocks, got %v", price)
+	}
+}
+
+func TestGasConfigMultiplierAndCap(t *testing.T) {
+	tr := NewTransactor(nil, big.NewInt(1))
+	fb := newFakeGasBackend([]int64{10})
+	if err := tr.ConfigureGas(GasConfig{Strategy: GasNode, Multiplier: 1.5, PriceCap: "100"}, fb); err != nil {
+		t.Fatal(err)
+	}
+	if price, _ := tr.SuggestGasPrice(context.Background()); price.Int64() != 10 {
+		t.Errorf("expect 7 * 1.5 rounded down, got %v", price)
+	}
+	fb.suggest = big.NewInt(1000)
+	if price, _ := tr.SuggestGasPrice(context.Background()); price.Int64() != 100 {
+		t.Errorf("expect the cap 100, got %v", price)
+	}
+
+	if err := tr.ConfigureGas(GasConfig{Strategy: "cheapest"}, fb); err == nil {
+		t.Error("expect an unknown strategy refused")
+	}
+	if err := tr.ConfigureGas(GasConfig{Strategy: GasFixed, Price: "0"}, fb); err != nil {
+		t.Fatal(err)
+	}
+	if price, _ := tr.SuggestGasPrice(context.Background()); price.Sign() != 0 {
+		t.Errorf("expect the fixed price 0, got %v", price)
+	}
+	if price, _ := NewTransactor(nil, big.NewInt(2)).SuggestGasPrice(context.Background()); price != nil {
+		t.Errorf("expect the strategy of another network untouched, got %v", price)
+	}
+}
+
+func TestGasLimitMargin(t *testing.T) {
+	tx := types.NewTransaction(3, common.Address{1}, big.NewInt(1), 50000, big.NewInt(2), []byte{1, 2})
+	raised := NewTransactor(nil, big.NewInt(1)).withGasMargin(tx)
+	if raised.Gas() != 60000 {
+		t.Errorf("expect 20%% margin, got %d", raised.Gas())
+	}
+	if raised.Nonce() != 3 || *raised.To() != *tx.To() || raised.GasPrice().Int64() != 2 || len(raised.Data()) != 2 {
+		t.Error("expect every other field kept")
+	}
+}
+
+func TestFixedGasPriceWithoutPrice(t *testing.T) {
+	tr := NewTransactor(nil, big.NewInt(1))
+	tr.SetGasStrategy(FixedGasPrice{}, 2, nil)
+	if _, err := tr.SuggestGasPrice(context.Background()); err == nil {
+		t.Error("expect an error for a fixed strategy without price")
+	}
+	// bind asks the node for the price instead
+	if opts := tr.BuildTransactOpts(&TransactParams{}); opts.GasPrice != nil {
+		t.Errorf("expect no gas price, got %v", opts.GasPrice)
+	}
+}
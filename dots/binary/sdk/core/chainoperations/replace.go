@@ -0,0 +1,92 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+	"math/big"
+)
+
+// cancelGasLimit is the gas of a plain transfer to an account.
+const cancelGasLimit = 21000
+
+// ErrNotSender is returned when the transaction to replace was sent by another account than txParams.From.
+var ErrNotSender = errors.New("transaction to replace was not sent by the account")
+
+// TxSender is the part of ethclient.Client a replacement is sent with.
+type TxSender interface {
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// SpeedUp sends the pending tx of txParams.From again with the same nonce and a higher gas price,
+// the node keeps the one mined first and drops the other.
+func (t *Transactor) SpeedUp(txParams *TransactParams, tx *types.Transaction, client TxSender) (*types.Transaction, error) {
+	return t.replace(txParams, tx, false, client)
+}
+
+// Cancel replaces the pending tx by a transfer of nothing to txParams.From with the same nonce.
+func (t *Transactor) Cancel(txParams *TransactParams, tx *types.Transaction, client TxSender) (*types.Transaction, error) {
+	return t.replace(txParams, tx, true, client)
+}
+
+func (t *Transactor) replace(txParams *TransactParams, tx *types.Transaction, cancel bool, client TxSender) (*types.Transaction, error) {
+	signer, err := t.Signer()
+	if err != nil {
+		return nil, err
+	}
+	// the nonce of another account would replace nothing and use up a nonce the manager does not know of
+	if from, err := types.Sender(signer, tx); err != nil || from != txParams.From {
+		return nil, ErrNotSender
+	}
+
+	ctx := context.Background()
+	price := bumpGasPrice(tx.GasPrice())
+	if suggested, err := t.SuggestGasPrice(ctx); err == nil && suggested != nil && suggested.Cmp(price) > 0 {
+		price = suggested
+	}
+
+	signed, err := SignTransaction(signer, txParams.From, replacementTx(txParams, tx, price, cancel), txParams.Password)
+	if err != nil {
+		return nil, err
+	}
+	err = client.SendTransaction(ctx, signed)
+	t.resyncNonce(ctx, txParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+// resyncNonce makes the nonce manager read the chain again after a transaction was sent outside of it,
+// e.g. a nonce too low means the replaced transaction was mined meanwhile.
+func (t *Transactor) resyncNonce(ctx context.Context, txParams *TransactParams) {
+	if t.nonces == nil {
+		return
+	}
+	if err := t.nonces.Resync(ctx, txParams.From); err != nil {
+		dot.Logger().Warnln("", zap.NamedError("failed to resync nonce after a replacement, error:", err))
+	}
+}
+
+// bumpGasPrice raises the price by 12.5%, more than the 10% nodes require of a replacement.
+func bumpGasPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Div(price, big.NewInt(8))
+	return bumped.Add(bumped, price).Add(bumped, big.NewInt(1))
+}
+
+func replacementTx(txParams *TransactParams, tx *types.Transaction, price *big.Int, cancel bool) *types.Transaction {
+	switch {
+	case cancel:
+		return types.NewTransaction(tx.Nonce(), txParams.From, big.NewInt(0), cancelGasLimit, price, nil)
+	case tx.To() == nil:
+		return types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), price, tx.Data())
+	default:
+		return types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), price, tx.Data())
+	}
+}
@@ -0,0 +1,59 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package chainoperations
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"testing"
+)
+
+func TestBumpGasPrice(t *testing.T) {
+	cases := map[int64]int64{0: 1, 8: 10, 1000000000: 1125000001}
+	for price, expect := range cases {
+		if got := bumpGasPrice(big.NewInt(price)); got.Int64() != expect {
+			t.Errorf("bump %d: expect %d, got %v", price, expect, got)
+		}
+	}
+}
+
+func TestReplacementTx(t *testing.T) {
+	txParams := &TransactParams{From: common.Address{9}}
+	tx := types.NewTransaction(4, common.Address{1}, big.NewInt(5), 80000, big.NewInt(10), []byte{1, 2, 3})
+
+	sped := replacementTx(txParams, tx, big.NewInt(12), false)
+	if sped.Nonce() != 4 || *sped.To() != *tx.To() || sped.Value().Int64() != 5 || sped.Gas() != 80000 ||
+		sped.GasPrice().Int64() != 12 || len(sped.Data()) != 3 {
+		t.Error("expect the same transaction with the new gas price")
+	}
+
+	cancel := replacementTx(txParams, tx, big.NewInt(12), true)
+	if cancel.Nonce() != 4 || *cancel.To() != txParams.From || cancel.Value().Sign() != 0 || cancel.Gas() != cancelGasLimit ||
+		cancel.GasPrice().Int64() != 12 || len(cancel.Data()) != 0 {
+		t.Error("expect an empty transfer to the sender with the same nonce")
+	}
+}
+
+func TestReplaceOnlyOwnTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTransactor(nil, big.NewInt(1337))
+	signer, _ := tr.Signer()
+	tx, err := types.SignTx(types.NewTransaction(4, common.Address{1}, big.NewInt(5), 21000, big.NewInt(10), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. a transaction of the deployer while the current user asks to replace it
+	if _, err := tr.SpeedUp(&TransactParams{From: common.Address{9}}, tx, nil); err != ErrNotSender {
+		t.Errorf("expect ErrNotSender, got %v", err)
+	}
+	if _, err := tr.Cancel(&TransactParams{From: common.Address{9}}, tx, nil); err != ErrNotSender {
+		t.Errorf("expect ErrNotSender, got %v", err)
+	}
+}
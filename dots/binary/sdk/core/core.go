@@ -6,17 +6,39 @@ package core
 import (
 	"context"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dot/dot"
 	chainevents2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainevents"
+	chainoperations2 "github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
+	events2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
 	accounts2 "github.com/scryinfo/dp/dots/binary/sdk/util/accounts"
 	ipfsaccess2 "github.com/scryinfo/dp/dots/binary/sdk/util/storage/ipfsaccess"
+	"github.com/scryinfo/dp/dots/eth/nonce"
 	"go.uber.org/zap"
+	"math/big"
+	"strings"
 )
 
 type Connector struct {
-	ctx  context.Context
-	conn *ethclient.Client
+	ctx        context.Context
+	conn       *ethclient.Client
+	rpc        *rpc.Client
+	transactor *chainoperations2.Transactor
+}
+
+func (c *Connector) Conn() *ethclient.Client {
+	return c.conn
+}
+
+// RPC is the client under Conn, for the calls ethclient has no method for.
+func (c *Connector) RPC() *rpc.Client {
+	return c.rpc
+}
+
+// Transactor sends the transactions of the network of the connector, signed for its chain id.
+func (c *Connector) Transactor() *chainoperations2.Transactor {
+	return c.transactor
 }
 
 //start
@@ -24,7 +46,11 @@ func StartEngine(ethNodeAddr string,
 	asServiceAddr string,
 	contracts []chainevents2.ContractInfo,
 	ipfsNodeAddr string,
-) (*ethclient.Client, error) {
+	appId string,
+	checkpointFile string,
+	deadLetterFile string,
+	chainID uint64,
+) (*Connector, *chainevents2.EventEngine, error) {
 	logger := dot.Logger()
 
 	defer func() {
@@ -36,38 +62,62 @@ func StartEngine(ethNodeAddr string,
 	err := ipfsaccess2.GetIAInstance().Initialize(ipfsNodeAddr)
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to initialize ipfs. error: ", err))
-		return nil, err
+		return nil, nil, err
 	}
 
 	connector, err := newConnector(ethNodeAddr)
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to initialize connector. error: ", err))
-		return nil, err
+		return nil, nil, err
 	}
 
+	// chainID 0 reads it from the node
+	id := new(big.Int).SetUint64(chainID)
+	if chainID == 0 {
+		if id, err = chainoperations2.ReadChainID(connector.ctx, connector.rpc, connector.conn); err != nil {
+			logger.Errorln("", zap.NamedError("failed to read chain id, error:", err))
+			return nil, nil, err
+		}
+	}
+	connector.transactor = chainoperations2.NewTransactor(nonce.NewManager(connector.conn), id)
+
 	err = accounts2.GetAMInstance().Initialize(asServiceAddr)
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to initialize account service, error:", err))
-		return nil, err
+		return nil, nil, err
 	}
 
-	chainevents2.StartEventProcessing(connector.conn, contracts)
+	var checkpoint events2.CheckpointStore
+	if checkpointFile != "" {
+		checkpoint = events2.NewFileCheckpointStore(checkpointFile)
+	}
+	engine := chainevents2.NewEventEngine(connector.conn, contracts, checkpoint, supportsPush(ethNodeAddr), appId)
+	if deadLetterFile != "" {
+		engine.SetDeadLetterStore(chainevents2.NewFileDeadLetterStore(deadLetterFile))
+	}
+	if err = engine.Start(); err != nil {
+		logger.Errorln("", zap.NamedError("failed to start event engine, error:", err))
+		return nil, nil, err
+	}
 
-	return connector.conn, nil
+	return connector, engine, nil
 }
 
-func StartScan(fromBlock uint64) {
-	chainevents2.SetFromBlock(fromBlock)
+// only websocket and IPC endpoints can push logs with eth_subscribe.
+func supportsPush(ethNodeAddr string) bool {
+	addr := strings.ToLower(ethNodeAddr)
+	return strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://") || strings.HasSuffix(addr, ".ipc")
 }
 
 func newConnector(ethNodeAddr string) (*Connector, error) {
-	cn, err := ethclient.Dial(ethNodeAddr)
+	rc, err := rpc.Dial(ethNodeAddr)
 	if err != nil {
 		return nil, errors.Wrap(err, "Connect to node: "+ethNodeAddr+" failed. ")
 	}
 
 	return &Connector{
 		ctx:  context.Background(),
-		conn: cn,
+		conn: ethclient.NewClient(rc),
+		rpc:  rc,
 	}, nil
 }
@@ -0,0 +1,170 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	redo2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/redo"
+	"sort"
+	"sync"
+)
+
+// rangesPerWorker bounds how far a backfill pass reads ahead of the range it delivers.
+const rangesPerWorker = 4
+
+// logChunk is the result of one range fetched by a backfill worker.
+type logChunk struct {
+	from, to uint64
+	logs     []types.Log
+	headers  map[common.Hash]*types.Header
+	span     uint64
+	split    bool
+	err      error
+	done     chan struct{}
+}
+
+// backfillEnd returns the last block a parallel pass may scan, only blocks that can not be
+// reorganized any more are backfilled, the blocks near the head are scanned range after range as before.
+func (es *eventScanner) backfillEnd(to uint64, newest uint64) (uint64, bool) {
+	if es.workers < 2 || newest < es.tracker.window {
+		return 0, false
+	}
+	stable := newest - es.tracker.window
+	if to > stable {
+		to = stable
+	}
+	if to < es.From+es.step {
+		return 0, false
+	}
+	if limit := es.From + uint64(es.workers*rangesPerWorker)*(es.step+1) - 1; to > limit {
+		to = limit
+	}
+	return to, true
+}
+
+// backfillPass fetches [es.From, to] in ranges of es.step on up to es.workers goroutines,
+// and delivers the logs range after range, so they arrive ordered by block and log index.
+// A failed range stops the pass, the ranges before it are delivered and the scan goes on from it later.
+func (es *eventScanner) backfillPass(ctx *redo2.RedoCtx, to uint64, newest uint64) {
+	fetchCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	contracts, topics := es.Contracts, es.topics
+	var chunks []*logChunk
+	for from := es.From; from <= to; from += es.step + 1 {
+		end := from + es.step
+		if end > to {
+			end = to
+		}
+		chunks = append(chunks, &logChunk{from: from, to: end, done: make(chan struct{})})
+	}
+	sem := make(chan struct{}, es.workers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, chunk := range chunks {
+			select {
+			case sem <- struct{}{}:
+			case <-fetchCtx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(chunk *logChunk) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				es.fetchChunk(fetchCtx, contracts, topics, chunk)
+			}(chunk)
+		}
+	}()
+
+	from, delivered := es.From, es.delivered
+	span, count, split := es.step, 0, false
+	var failed *logChunk
+	for _, chunk := range chunks {
+		<-chunk.done
+		if chunk.err != nil {
+			failed = chunk
+			break
+		}
+		for _, lg := range chunk.logs {
+			if header, ok := chunk.headers[lg.BlockHash]; ok {
+				es.headers.add(header)
+			}
+			es.deliverLog(lg)
+		}
+		if chunk.split {
+			split = true
+			if chunk.span < span {
+				span = chunk.span
+			}
+		}
+		if len(chunk.logs) > count {
+			count = len(chunk.logs)
+		}
+		es.From = chunk.to + 1
+		es.next = logPosition{block: es.From}
+	}
+	cancel()
+	wg.Wait()
+
+	if split {
+		es.step = span
+	} else {
+		es.growStep(es.step, count)
+	}
+	es.tracker.prune(newest)
+	if es.From > from {
+		es.sendProgress(Progress{From: from, To: es.From - 1, Head: newest + es.marginBlock, Events: es.delivered - delivered})
+		es.markCheckpoint(es.From - 1)
+	}
+	if failed != nil {
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: failed.from, Err: fmt.Errorf("filter log(%v,%v) err:%v, will retry later", failed.from, failed.to, failed.err)})
+		return
+	}
+	ctx.StartNextRightNow()
+}
+
+// fetchChunk reads every log of the chunk, bisecting like filterLogs when the node refuses a range,
+// and fetches the header of each block with logs, the scanner only reads the chunk after done is closed.
+func (es *eventScanner) fetchChunk(ctx context.Context, contracts contractMap, topics []common.Hash, chunk *logChunk) {
+	defer close(chunk.done)
+
+	chunk.span = chunk.to - chunk.from
+	for from := chunk.from; from <= chunk.to; {
+		logs, end, err := es.queryLogs(ctx, contracts, from, chunk.to, topics)
+		if err != nil {
+			chunk.err = err
+			return
+		}
+		if end < chunk.to {
+			chunk.split = true
+		}
+		if end-from < chunk.span {
+			chunk.span = end - from
+		}
+		chunk.logs = append(chunk.logs, logs...)
+		from = end + 1
+	}
+	sort.SliceStable(chunk.logs, func(i, j int) bool {
+		a, b := chunk.logs[i], chunk.logs[j]
+		return a.BlockNumber < b.BlockNumber || a.BlockNumber == b.BlockNumber && a.Index < b.Index
+	})
+
+	chunk.headers = make(map[common.Hash]*types.Header)
+	for _, lg := range chunk.logs {
+		if _, ok := chunk.headers[lg.BlockHash]; ok {
+			continue
+		}
+		header, err := es.conn.HeaderByHash(ctx, lg.BlockHash)
+		if err != nil {
+			// deliverLog queries it again and reports the failure in order
+			continue
+		}
+		chunk.headers[lg.BlockHash] = header
+	}
+}
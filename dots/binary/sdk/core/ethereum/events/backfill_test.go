@@ -0,0 +1,100 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newBackfillChain has logs blocks with two Ping logs each, followed by blocks without logs.
+func newBackfillChain(logs int, empty int) *fakeChain {
+	fc := newFakeChain()
+	for i := 0; i < logs; i++ {
+		fc.addBlock(pingLog(int64(i)), pingLog(int64(i)))
+	}
+	for i := 0; i < empty; i++ {
+		fc.addBlock()
+	}
+	return fc
+}
+
+func scanAll(t testing.TB, fc *fakeChain, workers int) []Event {
+	dataCh := make(chan Event, 10000)
+	b := newTestBuilder(t, fc, dataCh).SetStep(9).SetBackfillWorkers(workers)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	scanUntilIdle(b.es)
+	return drain(dataCh)
+}
+
+func TestBackfillKeepsOrder(t *testing.T) {
+	fc := newBackfillChain(300, 80)
+	fc.maxResults = 7
+	seq := scanAll(t, fc, 1)
+	par := scanAll(t, fc, 4)
+
+	if len(seq) != 600 {
+		t.Fatalf("expect 600 events, got %d", len(seq))
+	}
+	if len(par) != len(seq) {
+		t.Fatalf("expect %d events from the parallel scan, got %d", len(seq), len(par))
+	}
+	for i := range seq {
+		if seq[i].BlockNumber != par[i].BlockNumber || seq[i].LogIndex != par[i].LogIndex || seq[i].Timestamp != par[i].Timestamp {
+			t.Fatalf("event %d: expect block %d log %d, got block %d log %d",
+				i, seq[i].BlockNumber, seq[i].LogIndex, par[i].BlockNumber, par[i].LogIndex)
+		}
+	}
+}
+
+func TestBackfillHandsOverToTail(t *testing.T) {
+	fc := newBackfillChain(200, 0)
+	dataCh := make(chan Event, 1000)
+	cs := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint"))
+	b := newTestBuilder(t, fc, dataCh).SetStep(9).SetBackfillWorkers(4).SetCheckpointStore(cs)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	scanUntilIdle(b.es)
+	evts := drain(dataCh)
+	if len(evts) != 400 {
+		t.Fatalf("expect 400 events, got %d", len(evts))
+	}
+	for _, evt := range evts {
+		b.Ack(evt)
+	}
+	if _, ok := b.es.tracker.hash(200); !ok {
+		t.Error("expect the head block remembered for reorg detection")
+	}
+
+	fc.addBlock(pingLog(1))
+	scanUntilIdle(b.es)
+	evts = drain(dataCh)
+	if len(evts) != 1 || evts[0].BlockNumber != 201 {
+		t.Fatalf("expect the event of the new block, got %v", evts)
+	}
+	b.Ack(evts[0])
+	if saved, _, _ := cs.Load(); saved != 201 {
+		t.Errorf("expect checkpoint 201, got %d", saved)
+	}
+}
+
+func benchmarkBackfill(b *testing.B, workers int) {
+	fc := newBackfillChain(2000, 64)
+	fc.latency = time.Millisecond
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if evts := scanAll(b, fc, workers); len(evts) != 4000 {
+			b.Fatalf("expect 4000 events, got %d", len(evts))
+		}
+	}
+}
+
+func BenchmarkBackfillSequential(b *testing.B) { benchmarkBackfill(b, 1) }
+
+func BenchmarkBackfillParallel(b *testing.B) { benchmarkBackfill(b, 8) }
@@ -13,41 +13,55 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	redo2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/redo"
 	"math/big"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	errBadBool     = errors.New("abi: improperly encoded boolean value")
-	reflectHash    = reflect.TypeOf(common.Hash{})
-	reflectAddress = reflect.TypeOf(common.Address{})
-	reflectBigInt  = reflect.TypeOf(new(big.Int))
+	errBadBool = errors.New("abi: improperly encoded boolean value")
 )
 
 type Event struct {
 	BlockNumber uint64
-	TxHash      common.Hash
-	Address     common.Address
-	Name        string
-	Data        JSONObj
+	BlockHash   common.Hash
+	// Timestamp is the unix time of the block
+	Timestamp uint64
+	TxHash    common.Hash
+	TxIndex   uint
+	LogIndex  uint
+	Address   common.Address
+	Name      string
+	Data      JSONObj
+	// Typed points to the typed struct of the event, e.g. *DataPublish, nil for events without one.
+	Typed interface{}
+	// Removed is true when the event was retracted by a chain reorganization.
+	Removed bool
+	// Seq numbers the events sent by one scanner, it is what Builder.Ack acknowledges.
+	Seq uint64
 }
 
 type Progress struct {
 	From uint64
 	To   uint64
+	// Head is the newest block of the chain when the range was scanned
+	Head uint64
+	// Events is the number of events delivered from the range
+	Events int
 }
 
 func (evt Event) String() string {
 	return fmt.Sprintf(
-		`block: %v,tx: %s,address: %s,event: %s,data: %s`,
+		`block: %v,tx: %s,log: %v,address: %s,event: %s,removed: %v,data: %s`,
 		evt.BlockNumber,
 		evt.TxHash.Hex(),
+		evt.LogIndex,
 		evt.Address.Hex(),
 		evt.Name,
+		evt.Removed,
 		evt.Data.String(),
 	)
 }
@@ -59,11 +73,18 @@ type Builder struct {
 
 func NewScanBuilder() *Builder {
 	return &Builder{
-		es: &eventScanner{Contracts: make(contractMap)},
+		es: &eventScanner{Contracts: make(contractMap), tracker: newBlockTracker(defaultReorgWindow), headers: newHeaderCache(defaultHeaderCacheSize), acks: newAckTracker()},
 	}
 }
 
-func (b *Builder) SetClient(conn *ethclient.Client) *Builder {
+// ChainReader is the part of ethclient.Client used by the scanner.
+type ChainReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+func (b *Builder) SetClient(conn ChainReader) *Builder {
 	b.es.conn = conn
 	return b
 }
@@ -88,8 +109,16 @@ func (b *Builder) SetBlockMargin(margin uint64) *Builder {
 	return b
 }
 
+// the scanner remembers the hashes of the last window blocks it processed,
+// a reorganization inside the window is retracted and rescanned.
+func (b *Builder) SetReorgWindow(window uint64) *Builder {
+	b.es.tracker.window = window
+	return b
+}
+
 func (b *Builder) SetFrom(f uint64) *Builder {
 	b.es.From = f
+	b.es.next = logPosition{}
 	return b
 }
 
@@ -98,16 +127,39 @@ func (b *Builder) SetStep(f uint64) *Builder {
 	return b
 }
 
+// blocks older than the reorg window are fetched by up to workers concurrent range queries
+// and delivered in order, near the head the scanner goes on one range after another.
+func (b *Builder) SetBackfillWorkers(workers int) *Builder {
+	b.es.workers = workers
+	return b
+}
+
 func (b *Builder) SetTo(f uint64) *Builder {
 	b.es.To = f
 	return b
 }
 
+// the scanner saves the last processed block into cs once the events up to it were acknowledged by Ack,
+// every event sent on the data channel, removed ones too, has to be acknowledged.
+// Build resumes from the saved block when no from block was set.
+func (b *Builder) SetCheckpointStore(cs CheckpointStore) *Builder {
+	b.es.checkpoint = cs
+	b.es.acks.store = cs
+	return b
+}
+
 func (b *Builder) SetProgressChan(pc chan<- Progress) *Builder {
 	b.es.ProgressChan = pc
 	return b
 }
 
+// once the scanner caught up it switches to eth_subscribe if the client supports it,
+// and falls back to polling when the subscription drops.
+func (b *Builder) SetPushMode(yes bool) *Builder {
+	b.es.push = yes
+	return b
+}
+
 func (b *Builder) SetDataChan(dataCh chan<- Event, errChan chan<- error) *Builder {
 	b.es.DataChan, b.es.ErrChan = dataCh, errChan
 	return b
@@ -131,6 +183,41 @@ func (b *Builder) BuildAndRun() (*redo2.Recipet, error) {
 	return recipet, nil
 }
 
+// Rewind moves the scanner back to from, it is safe to call while the scanner is running.
+func (b *Builder) Rewind(from uint64) {
+	b.es.mu.Lock()
+	defer b.es.mu.Unlock()
+	b.es.dropSubscription()
+	if from == 0 {
+		b.es.tracker.records = make(map[uint64]*blockRecord)
+		b.es.acks.reset()
+	} else {
+		b.es.tracker.rewind(from - 1)
+		b.es.rewindCheckpoint(from - 1)
+	}
+	b.es.From = from
+	b.es.next = logPosition{}
+}
+
+// Ack tells the scanner the event was processed, the checkpoint only moves past acknowledged events.
+// It never blocks on the scanner and can be called from any goroutine.
+func (b *Builder) Ack(evt Event) {
+	if block, err := b.es.acks.ack(evt.Seq); err != nil {
+		b.es.sendErr(&ScanError{Kind: ErrorCheckpoint, Block: block, Err: fmt.Errorf("save scan checkpoint fail:%v", err)})
+	}
+}
+
+// Close ends the log subscription of push mode and drops the events not sent yet,
+// the polling job is stopped by its recipet.
+func (b *Builder) Close() {
+	b.es.mu.Lock()
+	defer b.es.mu.Unlock()
+	b.es.dropSubscription()
+	if b.es.queue != nil {
+		b.es.queue.close()
+	}
+}
+
 func (b *Builder) Build() error {
 	if b.es.DataChan == nil {
 		return errors.New("data channel should not be empty")
@@ -152,22 +239,35 @@ func (b *Builder) Build() error {
 	if b.es.StepLength == 0 {
 		b.es.StepLength = 1000
 	}
-
-	for key, cm := range b.es.Contracts {
-		if len(cm.evt_names) == 0 {
-			return errors.New("no event names")
+	b.es.step = b.es.StepLength
+	if b.es.From == 0 && b.es.checkpoint != nil {
+		saved, ok, err := b.es.checkpoint.Load()
+		if err != nil {
+			return fmt.Errorf("load scan checkpoint fail:%v", err)
 		}
-		if cm.abi_str == "" {
-			return errors.New("need ABI")
+		if ok {
+			b.es.From = saved + 1
+			b.es.acks.saved, b.es.acks.hasSaved = saved, true
 		}
-		bc, abi, err := bindContract(cm.abi_str, cm.contract, b.es.conn)
+	}
+
+	for key, cm := range b.es.Contracts {
+		bound, err := b.es.bindMeta(cm)
 		if err != nil {
 			return err
 		}
-		cm.bc = bc
-		cm.abi = abi
-		b.es.Contracts[key] = cm
+		b.es.Contracts[key] = bound
 	}
+	topics, err := b.es.Contracts.Topics()
+	if err != nil {
+		return err
+	}
+	b.es.mu.Lock()
+	b.es.topics, b.es.built = topics, true
+	if b.es.queue == nil {
+		b.es.queue = newEventQueue(b.es.DataChan)
+	}
+	b.es.mu.Unlock()
 	return nil
 }
 
@@ -294,7 +394,7 @@ func toGoType(index int, t abi.Type, output []byte) (interface{}, error) {
 	case abi.StringTy: // variable arrays are written at the end of the return bytes
 		return string(output[begin : begin+end]), nil
 	case abi.IntTy, abi.UintTy:
-		return readInteger(t.Kind, returnOutput), nil
+		return readInteger(t, returnOutput), nil
 	case abi.BoolTy:
 		return readBool(returnOutput)
 	case abi.AddressTy:
@@ -312,9 +412,9 @@ func toGoType(index int, t abi.Type, output []byte) (interface{}, error) {
 	}
 }
 
-// reads the integer based on its kind
-func readInteger(kind reflect.Kind, b []byte) interface{} {
-	switch kind {
+// reads the integer based on its kind, integers wider than 64 bits are *big.Int in two's complement
+func readInteger(t abi.Type, b []byte) interface{} {
+	switch t.Kind {
 	case reflect.Uint8:
 		return b[len(b)-1]
 	case reflect.Uint16:
@@ -332,7 +432,11 @@ func readInteger(kind reflect.Kind, b []byte) interface{} {
 	case reflect.Int64:
 		return int64(binary.BigEndian.Uint64(b[len(b)-8:]))
 	default:
-		return new(big.Int).SetBytes(b)
+		num := new(big.Int).SetBytes(b)
+		if t.T == abi.IntTy && len(b) > 0 && b[0]&0x80 != 0 {
+			num.Sub(num, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+		return num
 	}
 }
 
@@ -441,83 +545,42 @@ func parseTopics(out JSONObj, fields abi.Arguments, topics []common.Hash) error
 	if len(fields) != len(topics) {
 		return errors.New("topic/field count mismatch")
 	}
-	// Iterate over all the fields and reconstruct them from topics
-	for _, arg := range fields {
+	for i, arg := range fields {
 		if !arg.Indexed {
 			return errors.New("non-indexed field in topic reconstruction")
 		}
-		name := arg.Name
-		// Try to parse the topic back into the fields based on primitive types
-		switch arg.Type.Kind {
-		case reflect.Bool:
-			if topics[0][common.HashLength-1] == 1 {
-				out.Set(name, true)
-			} else {
-				out.Set(name, false)
-			}
-		case reflect.Int8:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, num.Int64())
-
-		case reflect.Int16:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, num.Int64())
-
-		case reflect.Int32:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, num.Int64())
-
-		case reflect.Int64:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, num.Int64())
-
-		case reflect.Uint8:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, uint8(num.Int64()))
-
-		case reflect.Uint16:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, uint16(num.Int64()))
-
-		case reflect.Uint32:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, uint32(num.Int64()))
-
-		case reflect.Uint64:
-			num := new(big.Int).SetBytes(topics[0][:])
-			out.Set(name, num.Uint64())
-
-		default:
-			// Ran out of plain primitive types, try custom types
-			switch arg.Type.Type {
-			case reflectHash: // Also covers all dynamic types
-				out.Set(name, topics[0].Hex())
-
-			case reflectAddress:
-				var addr common.Address
-				copy(addr[:], topics[0][common.HashLength-common.AddressLength:])
-				out.Set(name, addr.Hex())
-
-			case reflectBigInt:
-				num := new(big.Int).SetBytes(topics[0][:])
-				out.Set(name, num)
-
-			default:
-				// Ran out of custom types, try the crazies
-				switch {
-				case arg.Type.T == abi.FixedBytesTy:
-					out.Set(name, topics[0][common.HashLength-arg.Type.Size:])
-
-				default:
-					return fmt.Errorf("unsupported indexed type: %v", arg.Type)
-				}
-			}
+		value, err := readTopic(arg.Type, topics[i])
+		if err != nil {
+			return fmt.Errorf("indexed argument %s: %v", arg.Name, err)
 		}
-		topics = topics[1:]
+		out.Set(arg.Name, value)
 	}
 	return nil
 }
 
+// readTopic decodes an indexed argument. Values of dynamic types (string, bytes, arrays, tuples)
+// are not stored in the log, the topic only holds their keccak256 hash, it is returned as common.Hash.
+func readTopic(t abi.Type, topic common.Hash) (interface{}, error) {
+	switch t.T {
+	case abi.BoolTy:
+		return readBool(topic[:])
+	case abi.IntTy, abi.UintTy:
+		return readInteger(t, topic[:]), nil
+	case abi.AddressTy:
+		return common.BytesToAddress(topic[:]), nil
+	case abi.HashTy:
+		return topic, nil
+	case abi.FixedBytesTy:
+		return readFixedBytes(t, topic[:])
+	case abi.FunctionTy:
+		return readFunctionType(t, topic[:])
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return topic, nil
+	default:
+		return nil, fmt.Errorf("unsupported indexed type: %v", t)
+	}
+}
+
 type contractMap map[string]contractMeta
 
 func (cm contractMap) Contracts() []common.Address {
@@ -530,6 +593,25 @@ func (cm contractMap) Contracts() []common.Address {
 	return arr
 }
 
+// Topics returns the ids of the configured events, used as topic[0] filter of FilterLogs.
+func (cm contractMap) Topics() ([]common.Hash, error) {
+	var topics []common.Hash
+	seen := make(map[common.Hash]bool)
+	for _, meta := range cm {
+		for _, name := range meta.evt_names {
+			evt, ok := meta.abi.Events[name]
+			if !ok {
+				return nil, fmt.Errorf("event %s is not in the abi of %s", name, meta.contract.Hex())
+			}
+			if id := evt.Id(); !seen[id] {
+				seen[id] = true
+				topics = append(topics, id)
+			}
+		}
+	}
+	return topics, nil
+}
+
 func (cm contractMap) GetMeta(addr common.Address) (contractMeta, bool) {
 	meta, ok := cm[strings.ToLower(addr.Hex())]
 	if !ok {
@@ -541,44 +623,116 @@ func (cm contractMap) GetMeta(addr common.Address) (contractMeta, bool) {
 }
 
 type eventScanner struct {
-	conn          *ethclient.Client
+	conn          ChainReader
 	Contracts     contractMap
 	From          uint64
 	StepLength    uint64
+	step          uint64
+	resultLimit   int
+	workers       int
+	topics        []common.Hash
 	To            uint64
 	DataChan      chan<- Event
 	ErrChan       chan<- error
 	ProgressChan  chan<- Progress
 	GracefullExit bool
 	marginBlock   uint64
+	checkpoint    CheckpointStore
+	acks          *ackTracker
+	tracker       *blockTracker
+	headers       *headerCache
+	push          bool
+	delivered     int
+	droppedErrs   int
+	errMu         sync.Mutex
+	queue         *eventQueue
+	sub           ethereum.Subscription
+	// pushed are the logs of the subscription waiting for the block margin, in log order
+	pushed []types.Log
+	// pushHead is the newest head seen while subscribed
+	pushHead uint64
+	next     logPosition
+	// built is set once Build bound the contracts
+	built bool
+	mu    sync.Mutex
 }
 
 func (es *eventScanner) NewestBlockNumber() (uint64, error) {
+	head, err := es.headBlockNumber()
+	if err != nil {
+		return 0, err
+	}
+	return head - es.marginBlock, nil
+}
+
+func (es *eventScanner) headBlockNumber() (uint64, error) {
 	block, err := es.conn.HeaderByNumber(context.Background(), nil)
 	if err != nil {
 		return 0, err
 	}
-	return block.Number.Uint64() - es.marginBlock, nil
+	return block.Number.Uint64(), nil
+}
+
+// markCheckpoint is called once the events up to block were sent,
+// the checkpoint moves there after the consumer acknowledged them.
+func (es *eventScanner) markCheckpoint(block uint64) {
+	if block, err := es.acks.mark(block); err != nil {
+		es.sendErr(&ScanError{Kind: ErrorCheckpoint, Block: block, Err: fmt.Errorf("save scan checkpoint fail:%v", err)})
+	}
 }
 
-func (es *eventScanner) sendErr(err error) {
-	if es.ErrChan != nil && err != nil {
-		es.ErrChan <- err
+func (es *eventScanner) rewindCheckpoint(block uint64) {
+	if _, err := es.acks.rewind(block); err != nil {
+		es.sendErr(&ScanError{Kind: ErrorCheckpoint, Block: block, Err: fmt.Errorf("save scan checkpoint fail:%v", err)})
 	}
 }
 
+// sendProgress never blocks the scanner, a progress nobody reads in time is dropped.
+func (es *eventScanner) sendProgress(p Progress) {
+	if es.ProgressChan == nil {
+		return
+	}
+	select {
+	case es.ProgressChan <- p:
+	default:
+	}
+}
+
+// sendData queues the event, it never blocks on the data channel.
 func (es *eventScanner) sendData(evt Event) {
 	if es.DataChan != nil {
-		es.DataChan <- evt
+		es.acks.send(&evt)
+		es.queue.push(evt)
 	}
 }
 
+// scan runs a pass with es.mu held, then waits outside of it until the consumer took the events.
 func (es *eventScanner) scan(ctx *redo2.RedoCtx) {
+	es.scanPass(ctx)
+	es.queue.drain()
+}
+
+func (es *eventScanner) scanPass(ctx *redo2.RedoCtx) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	// logs are pushed by the subscription while it is alive, the pass releases the queued ones
+	// and moves past the quiet blocks
+	if es.sub != nil {
+		head, err := es.headBlockNumber()
+		if err != nil {
+			es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("query newest block number fail:%v, will retry later", err)})
+			return
+		}
+		es.pushHead = head
+		es.releasePushed(head)
+		return
+	}
 	newest_bn, err := es.NewestBlockNumber()
 	if err != nil {
 		// not send this err
 		if !strings.Contains(err.Error(), "got null header for uncle") {
-			es.sendErr(fmt.Errorf("query newest block number fail:%v, will retry later", err))
+			es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("query newest block number fail:%v, will retry later", err)})
 		}
 		return
 	}
@@ -596,55 +750,119 @@ func (es *eventScanner) scan(ctx *redo2.RedoCtx) {
 		return
 	}
 	if to_bn < es.From {
+		es.trySubscribe()
 		return
 	}
-	if es.From+es.StepLength < to_bn {
-		to_bn = es.From + es.StepLength
+	last_bn := to_bn
+	if es.From+es.step < to_bn {
+		to_bn = es.From + es.step
 	}
-
-	fq := ethereum.FilterQuery{
-		FromBlock: new(big.Int).SetUint64(es.From),
-		ToBlock:   new(big.Int).SetUint64(to_bn),
-		Addresses: []common.Address{},
-		Topics:    [][]common.Hash{},
+	if fork, reorged, err := es.detectReorg(); err != nil {
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("check reorg err:%v, will retry later", err)})
+		return
+	} else if reorged {
+		es.rewind(fork)
+		ctx.StartNextRightNow()
+		return
+	}
+	if end, ok := es.backfillEnd(last_bn, newest_bn); ok {
+		es.backfillPass(ctx, end, newest_bn)
+		return
 	}
-	fq.Addresses = es.Contracts.Contracts()
-	logs, err := es.conn.FilterLogs(context.Background(), fq)
+
+	logs, to_bn, err := es.filterLogs(es.From, to_bn)
 	if err != nil {
-		es.sendErr(fmt.Errorf("filter log(%v,%v) err:%v, will retry later", es.From, to_bn, err))
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("filter log(%v,%v) err:%v, will retry later", es.From, to_bn, err)})
 		return
 	}
-	for _, lg := range logs {
-		evt := NewJSONObj()
-		cm, ok := es.Contracts.GetMeta(lg.Address)
-		if !ok {
-			continue
+	// only blocks that still can be reorganized are remembered
+	var toHeader *types.Header
+	if to_bn+es.tracker.window > newest_bn {
+		if toHeader, err = es.conn.HeaderByNumber(context.Background(), new(big.Int).SetUint64(to_bn)); err != nil {
+			es.sendErr(&ScanError{Kind: ErrorRPC, Block: to_bn, Err: fmt.Errorf("query header err:%v, will retry later", err)})
+			return
 		}
-		name, err := unpackMatchedLog(evt, lg, &cm)
-		if err != nil {
-			es.sendErr(fmt.Errorf("unpack %s log in tx(%s) fail:%v,abadon", name, lg.TxHash.Hex(), err))
-			continue
-		}
-		if !cm.HasEvent(name) {
-			continue
-		}
-		es.sendData(Event{
-			BlockNumber: lg.BlockNumber,
-			TxHash:      lg.TxHash,
-			Address:     lg.Address,
-			Name:        name,
-			Data:        evt,
-		})
+		es.headers.add(toHeader)
+		es.tracker.prune(newest_bn)
 	}
-	if es.ProgressChan != nil {
-		es.ProgressChan <- Progress{From: es.From, To: to_bn}
+	delivered := es.delivered
+	for _, lg := range logs {
+		es.deliverLog(lg)
 	}
+	if toHeader != nil {
+		es.tracker.record(to_bn, toHeader.Hash())
+	}
+	es.sendProgress(Progress{From: es.From, To: to_bn, Head: newest_bn + es.marginBlock, Events: es.delivered - delivered})
+	es.markCheckpoint(to_bn)
+	es.From = to_bn + 1
+	es.next = logPosition{block: es.From}
 	if to_bn < newest_bn {
 		ctx.StartNextRightNow()
+	} else {
+		es.trySubscribe()
 	}
-	es.From = to_bn + 1
 }
 
+// deliverLog decodes a log and sends it, logs before es.next were delivered already.
+func (es *eventScanner) deliverLog(lg types.Log) {
+	if !es.next.before(lg) {
+		return
+	}
+	es.next = logPosition{block: lg.BlockNumber, index: lg.Index + 1}
+
+	event, ok, se := es.Contracts.decodeLog(lg)
+	if se != nil {
+		es.sendErr(se)
+	}
+	if !ok {
+		return
+	}
+	if cm, _ := es.Contracts.GetMeta(lg.Address); !cm.HasEvent(event.Name) {
+		return
+	}
+	header, err := es.header(context.Background(), lg.BlockHash)
+	if err != nil {
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: lg.BlockNumber, TxHash: lg.TxHash, Err: fmt.Errorf("query header %s fail:%v, event without timestamp", lg.BlockHash.Hex(), err)})
+	} else {
+		event.Timestamp = header.Time
+	}
+	es.tracker.addEvent(lg.BlockHash, event)
+	es.sendData(event)
+	es.delivered++
+}
+
+// decodeLog decodes a log of any event in the contract abi, the timestamp is left empty.
+// ok is false when the log can not be delivered, se is set for every decode problem.
+func (cm contractMap) decodeLog(lg types.Log) (event Event, ok bool, se *ScanError) {
+	meta, ok := cm.GetMeta(lg.Address)
+	if !ok {
+		return
+	}
+	evt := NewJSONObj()
+	name, err := unpackMatchedLog(evt, lg, &meta)
+	if err == errUnknownEvent {
+		return event, false, &ScanError{Kind: ErrorUnknownEvent, Block: lg.BlockNumber, TxHash: lg.TxHash, Err: fmt.Errorf("log of %s with topic %s,abadon", lg.Address.Hex(), lg.Topics[0].Hex())}
+	} else if err != nil {
+		return event, false, &ScanError{Kind: ErrorDecode, Block: lg.BlockNumber, TxHash: lg.TxHash, Err: fmt.Errorf("unpack %s log fail:%v,abadon", name, err)}
+	}
+	event = Event{
+		BlockNumber: lg.BlockNumber,
+		BlockHash:   lg.BlockHash,
+		TxHash:      lg.TxHash,
+		TxIndex:     lg.TxIndex,
+		LogIndex:    lg.Index,
+		Address:     lg.Address,
+		Name:        name,
+		Data:        evt,
+	}
+	if event.Typed, err = DecodeTyped(name, evt); err != nil {
+		se = &ScanError{Kind: ErrorDecode, Block: lg.BlockNumber, TxHash: lg.TxHash, Err: fmt.Errorf("decode typed %s log fail:%v", name, err)}
+	}
+	return event, true, se
+}
+
+var errUnknownEvent = errors.New("Can't find mathed event")
+
 func unpackMatchedLog(out JSONObj, log types.Log, meta *contractMeta) (string, error) {
 	topic_hex := log.Topics[0].Hex()
 	for name, evt := range meta.abi.Events {
@@ -652,7 +870,27 @@ func unpackMatchedLog(out JSONObj, log types.Log, meta *contractMeta) (string, e
 			return name, meta.UnpackLogToJson(out, name, log)
 		}
 	}
-	return "", errors.New("Can't find mathed event")
+	return "", errUnknownEvent
+}
+
+func (es *eventScanner) bindMeta(cm contractMeta) (contractMeta, error) {
+	if len(cm.evt_names) == 0 {
+		return cm, errors.New("no event names")
+	}
+	if cm.abi_str == "" {
+		return cm, errors.New("need ABI")
+	}
+	var backend bind.ContractBackend
+	if cb, ok := es.conn.(bind.ContractBackend); ok {
+		backend = cb
+	}
+	bc, abi, err := bindContract(cm.abi_str, cm.contract, backend)
+	if err != nil {
+		return cm, err
+	}
+	cm.bc = bc
+	cm.abi = abi
+	return cm, nil
 }
 
 func bindContract(abi_str string, address common.Address, backend bind.ContractBackend) (*bind.BoundContract, *abi.ABI, error) {
@@ -0,0 +1,197 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CheckpointStore keeps the last block the scanner has fully processed,
+// so a restarted scanner can resume instead of jumping to the newest block.
+type CheckpointStore interface {
+	// Load returns the saved block, ok is false when nothing was saved yet.
+	Load() (block uint64, ok bool, err error)
+	Save(block uint64) error
+}
+
+type FileCheckpointStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+func (fs *FileCheckpointStore) Load() (uint64, bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, err := ioutil.ReadFile(fs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	str := strings.TrimSpace(string(data))
+	if str == "" {
+		return 0, false, nil
+	}
+	block, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return block, true, nil
+}
+
+// Save writes to a temp file first and renames it, a crash never leaves a half written checkpoint.
+func (fs *FileCheckpointStore) Save(block uint64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if dir := filepath.Dir(fs.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := fs.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(block, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.path)
+}
+
+// ackTracker holds the checkpoint back until the consumer acknowledged every event sent before it,
+// a crash then never loses events that were scanned but not processed yet.
+type ackTracker struct {
+	store CheckpointStore
+	// next is the Seq of the next event sent
+	next uint64
+	// done is the Seq below which every event was acknowledged
+	done  uint64
+	acked map[uint64]bool
+	// marks are the scanned blocks waiting for their events, oldest first
+	marks    []ackMark
+	saved    uint64
+	hasSaved bool
+	// holds counts the backfills of added contracts still sending events below the marks
+	holds int
+	mu    sync.Mutex
+}
+
+// ackMark is block once every event before seq is acknowledged.
+type ackMark struct {
+	block uint64
+	seq   uint64
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{acked: make(map[uint64]bool)}
+}
+
+func (at *ackTracker) send(evt *Event) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	evt.Seq = at.next
+	at.next++
+}
+
+// mark is called once every event up to block was sent.
+func (at *ackTracker) mark(block uint64) (uint64, error) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if at.store == nil {
+		return block, nil
+	}
+	at.marks = append(at.marks, ackMark{block: block, seq: at.next})
+	return at.flush()
+}
+
+func (at *ackTracker) ack(seq uint64) (uint64, error) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if at.store == nil || seq < at.done || seq >= at.next {
+		return 0, nil
+	}
+	at.acked[seq] = true
+	for at.acked[at.done] {
+		delete(at.acked, at.done)
+		at.done++
+	}
+	return at.flush()
+}
+
+// rewind forgets the marks after block and moves a saved checkpoint after block back to it.
+func (at *ackTracker) rewind(block uint64) (uint64, error) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	i := len(at.marks)
+	for i > 0 && at.marks[i-1].block > block {
+		i--
+	}
+	at.marks = at.marks[:i]
+	if at.store == nil || !at.hasSaved || at.saved <= block {
+		return block, nil
+	}
+	return block, at.save(block)
+}
+
+// hold keeps the checkpoint where it is until release.
+func (at *ackTracker) hold() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.holds++
+}
+
+// release lets the marks wait for the events sent while they were held too.
+func (at *ackTracker) release() (uint64, error) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if at.holds--; at.holds > 0 || at.store == nil {
+		return 0, nil
+	}
+	for i := range at.marks {
+		at.marks[i].seq = at.next
+	}
+	return at.flush()
+}
+
+// reset forgets every mark, the saved checkpoint stays.
+func (at *ackTracker) reset() {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.marks = nil
+}
+
+// flush saves the newest mark whose events are all acknowledged, it is called with at.mu held.
+func (at *ackTracker) flush() (uint64, error) {
+	if at.holds > 0 {
+		return 0, nil
+	}
+	i := 0
+	for i < len(at.marks) && at.marks[i].seq <= at.done {
+		i++
+	}
+	if i == 0 {
+		return 0, nil
+	}
+	block := at.marks[i-1].block
+	at.marks = at.marks[i:]
+	return block, at.save(block)
+}
+
+// save runs with at.mu held, so checkpoints of the scanner and of acks are written in order.
+func (at *ackTracker) save(block uint64) error {
+	if err := at.store.Save(block); err != nil {
+		return err
+	}
+	at.saved, at.hasSaved = block, true
+	return nil
+}
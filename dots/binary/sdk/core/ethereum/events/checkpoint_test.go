@@ -0,0 +1,101 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCheckpointStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan", "checkpoint")
+	cs := NewFileCheckpointStore(path)
+	if _, ok, err := cs.Load(); err != nil || ok {
+		t.Fatalf("expect no checkpoint before the first save, got ok %v err %v", ok, err)
+	}
+
+	if err := cs.Save(42); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Save(43); err != nil {
+		t.Fatal(err)
+	}
+	block, ok, err := NewFileCheckpointStore(path).Load()
+	if err != nil || !ok || block != 43 {
+		t.Fatalf("expect checkpoint 43, got %d ok %v err %v", block, ok, err)
+	}
+}
+
+// newCheckpointBuilder leaves from empty, so Build resumes from the checkpoint.
+func newCheckpointBuilder(t *testing.T, fc *fakeChain, dataCh chan Event, cs CheckpointStore) *Builder {
+	b := NewScanBuilder().
+		SetClient(fc).
+		SetContract(testContract, testAbi, "Ping").
+		SetDataChan(dataCh, make(chan error, 100)).
+		SetCheckpointStore(cs)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCheckpointWaitsForAcks(t *testing.T) {
+	fc := newBackfillChain(10, 0)
+	dataCh := make(chan Event, 100)
+	cs := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint"))
+	if err := cs.Save(0); err != nil {
+		t.Fatal(err)
+	}
+	b := newCheckpointBuilder(t, fc, dataCh, cs)
+
+	scanUntilIdle(b.es)
+	evts := drain(dataCh)
+	if len(evts) != 20 {
+		t.Fatalf("expect 20 events, got %d", len(evts))
+	}
+	if saved, _, _ := cs.Load(); saved != 0 {
+		t.Fatalf("expect the checkpoint to wait for the acks, got %d", saved)
+	}
+
+	// acks come out of order from parallel subscribers
+	for _, evt := range evts[1:] {
+		b.Ack(evt)
+	}
+	if saved, _, _ := cs.Load(); saved != 0 {
+		t.Fatalf("expect the checkpoint to wait for the first event, got %d", saved)
+	}
+	b.Ack(evts[0])
+	if saved, _, _ := cs.Load(); saved != 10 {
+		t.Fatalf("expect checkpoint 10, got %d", saved)
+	}
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	fc := newBackfillChain(10, 0)
+	dataCh := make(chan Event, 100)
+	cs := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint"))
+	if err := cs.Save(0); err != nil {
+		t.Fatal(err)
+	}
+	b := newCheckpointBuilder(t, fc, dataCh, cs)
+	scanUntilIdle(b.es)
+	for _, evt := range drain(dataCh) {
+		b.Ack(evt)
+	}
+
+	// the events of the new blocks are lost in a crash before they were processed
+	fc.addBlock(pingLog(10))
+	fc.addBlock(pingLog(11))
+	scanUntilIdle(b.es)
+	if evts := drain(dataCh); len(evts) != 2 {
+		t.Fatalf("expect 2 events of the new blocks, got %d", len(evts))
+	}
+
+	restarted := newCheckpointBuilder(t, fc, dataCh, cs)
+	scanUntilIdle(restarted.es)
+	evts := drain(dataCh)
+	if len(evts) != 2 || evts[0].BlockNumber != 11 || evts[1].BlockNumber != 12 {
+		t.Fatalf("expect the events of block 11 and 12 again, got %v", evts)
+	}
+}
@@ -0,0 +1,156 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// AddContract watches one more contract on a built or running scanner, the scan goes on from its position
+// with the new contract. With a non nil from the logs of the contract from that block up to the scan position
+// are backfilled after that, 0 backfills from genesis. The backfill does not stop the scan, so the backfilled
+// events can arrive after newer events of every contract, the new one included.
+// The checkpoint waits for the backfill. When the backfill fails the contract stays watched,
+// the returned *ScanError tells the first block that was not backfilled.
+func (b *Builder) AddContract(ctx context.Context, addr common.Address, abi_str string, from *uint64, evt_names ...string) error {
+	es := b.es
+	if addr == (common.Address{}) {
+		return errors.New("couldn't add zero contract to a running scanner")
+	}
+	meta, err := es.bindMeta(contractMeta{contract: addr, abi_str: abi_str, evt_names: evt_names})
+	if err != nil {
+		return err
+	}
+	single := contractMap{strings.ToLower(addr.Hex()): meta}
+	topics, err := single.Topics()
+	if err != nil {
+		return err
+	}
+
+	es.mu.Lock()
+	if _, ok := es.Contracts[strings.ToLower(addr.Hex())]; ok {
+		es.mu.Unlock()
+		return fmt.Errorf("contract %s is watched already", addr.Hex())
+	}
+	if _, ok := es.Contracts[strings.ToLower((common.Address{}).Hex())]; ok {
+		es.mu.Unlock()
+		return errors.New("couldn't add contract to a scanner of any contract")
+	}
+	contracts := es.Contracts.with(meta)
+	allTopics, err := contracts.Topics()
+	if err != nil {
+		es.mu.Unlock()
+		return err
+	}
+	es.Contracts, es.topics = contracts, allTopics
+	es.dropSubscription()
+	// es.From is the next block to scan, the scan delivers the new contract from there
+	next := es.From
+	backfill := from != nil && next > *from
+	if backfill {
+		es.acks.hold()
+	}
+	es.mu.Unlock()
+
+	if !backfill {
+		return nil
+	}
+	err = es.backfill(ctx, single, topics, *from, next-1)
+	if block, serr := es.acks.release(); serr != nil {
+		es.sendErr(&ScanError{Kind: ErrorCheckpoint, Block: block, Err: fmt.Errorf("save scan checkpoint fail:%v", serr)})
+	}
+	return err
+}
+
+// RemoveContract stops watching the contract, the events already delivered stay delivered.
+func (b *Builder) RemoveContract(addr common.Address) error {
+	es := b.es
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	key := strings.ToLower(addr.Hex())
+	if _, ok := es.Contracts[key]; !ok {
+		return fmt.Errorf("contract %s is not watched", addr.Hex())
+	}
+	if len(es.Contracts) == 1 {
+		return errors.New("couldn't remove the last contract")
+	}
+	contracts := es.Contracts.without(key)
+	topics, err := contracts.Topics()
+	if err != nil {
+		return err
+	}
+	es.Contracts, es.topics = contracts, topics
+	es.dropSubscription()
+	return nil
+}
+
+// backfill delivers the logs of contracts in [from, to] while the scan goes on, it takes es.mu only to deliver.
+func (es *eventScanner) backfill(ctx context.Context, contracts contractMap, topics []common.Hash, from uint64, to uint64) error {
+	for from <= to {
+		end := to
+		if end > from+es.StepLength {
+			end = from + es.StepLength
+		}
+		logs, end, err := es.queryLogs(ctx, contracts, from, end, topics)
+		if err != nil {
+			return &ScanError{Kind: ErrorRPC, Block: from, Err: fmt.Errorf("backfill log(%v,%v) err:%v", from, end, err)}
+		}
+		for _, lg := range logs {
+			event, ok, se := contracts.decodeLog(lg)
+			if se != nil {
+				es.sendErr(se)
+			}
+			if !ok {
+				continue
+			}
+			if header, err := es.header(ctx, lg.BlockHash); err == nil {
+				event.Timestamp = header.Time
+			}
+			es.mu.Lock()
+			if hash, ok := es.tracker.hash(lg.BlockNumber); ok && hash == lg.BlockHash {
+				es.tracker.addEvent(lg.BlockHash, event)
+			}
+			es.delivered++
+			es.mu.Unlock()
+			es.sendData(event)
+		}
+		from = end + 1
+	}
+	return nil
+}
+
+// dropSubscription makes the next scan pass subscribe again with the new addresses and topics,
+// the queued pushed logs are polled again.
+func (es *eventScanner) dropSubscription() {
+	if es.sub != nil {
+		es.sub.Unsubscribe()
+		es.sub = nil
+	}
+	es.pushed = nil
+}
+
+// with and without copy the map, a query holding the old map is not disturbed.
+func (cm contractMap) with(meta contractMeta) contractMap {
+	contracts := make(contractMap, len(cm)+1)
+	for key, m := range cm {
+		contracts[key] = m
+	}
+	contracts[strings.ToLower(meta.contract.Hex())] = meta
+	return contracts
+}
+
+func (cm contractMap) without(key string) contractMap {
+	contracts := make(contractMap, len(cm))
+	for k, m := range cm {
+		if k != key {
+			contracts[k] = m
+		}
+	}
+	return contracts
+}
@@ -0,0 +1,140 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddAndRemoveContractAtRuntime(t *testing.T) {
+	second := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	secondPing := func(value int64) types.Log {
+		lg := pingLog(value)
+		lg.Address = second
+		return lg
+	}
+
+	fc := newFakeChain()
+	fc.addBlock(pingLog(1), secondPing(10))
+	fc.addBlock(secondPing(20))
+	fc.addBlock(pingLog(3))
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fc, dataCh)
+	scanUntilIdle(b.es)
+	if got := drain(dataCh); len(got) != 2 {
+		t.Fatalf("want 2 events of the first contract, got %d", len(got))
+	}
+
+	from := uint64(2)
+	if err := b.AddContract(context.Background(), second, testAbi, &from, "Ping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddContract(context.Background(), second, testAbi, &from, "Ping"); err == nil {
+		t.Error("want error for a contract added twice")
+	}
+	backfilled := waitEvents(t, dataCh, 1)
+	if len(backfilled) != 1 || backfilled[0].Address != second || backfilled[0].BlockNumber != 2 {
+		t.Fatalf("want the block 2 event of the new contract backfilled, got %v", backfilled)
+	}
+
+	fc.addBlock(pingLog(4), secondPing(40))
+	scanUntilIdle(b.es)
+	if got := drain(dataCh); len(got) != 2 {
+		t.Fatalf("want events of both contracts, got %d", len(got))
+	}
+
+	if err := b.RemoveContract(testContract); err != nil {
+		t.Fatal(err)
+	}
+	fc.addBlock(pingLog(5), secondPing(50))
+	scanUntilIdle(b.es)
+	got := drain(dataCh)
+	if len(got) != 1 || got[0].Address != second {
+		t.Fatalf("want only the event of the remaining contract, got %v", got)
+	}
+	if err := b.RemoveContract(second); err == nil {
+		t.Error("want error when removing the last contract")
+	}
+}
+
+func TestAddContractBackfillFromGenesis(t *testing.T) {
+	second := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	third := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	pingOf := func(addr common.Address, value int64) types.Log {
+		lg := pingLog(value)
+		lg.Address = addr
+		return lg
+	}
+
+	fc := newFakeChain()
+	fc.addBlock(pingOf(second, 1), pingOf(third, 1))
+	fc.addBlock(pingOf(second, 2), pingOf(third, 2))
+	dataCh := make(chan Event, 100)
+	cs := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint"))
+	b := newTestBuilder(t, fc, dataCh).SetCheckpointStore(cs)
+	scanUntilIdle(b.es)
+	for _, evt := range drain(dataCh) {
+		b.Ack(evt)
+	}
+
+	genesis := uint64(0)
+	if err := b.AddContract(context.Background(), second, testAbi, &genesis, "Ping"); err != nil {
+		t.Fatal(err)
+	}
+	backfilled := waitEvents(t, dataCh, 2)
+	if len(backfilled) != 2 || backfilled[0].BlockNumber != 1 || backfilled[1].BlockNumber != 2 {
+		t.Fatalf("want both events of the new contract from genesis, got %v", backfilled)
+	}
+	if err := b.AddContract(context.Background(), third, testAbi, nil, "Ping"); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(dataCh); len(got) != 0 {
+		t.Fatalf("want no backfill without from, got %v", got)
+	}
+
+	// the checkpoint passes the scanned blocks only after the backfilled events were acknowledged
+	fc.addBlock()
+	scanUntilIdle(b.es)
+	if saved, _, _ := cs.Load(); saved != 2 {
+		t.Fatalf("want checkpoint 2 while the backfill is not acknowledged, got %d", saved)
+	}
+	for _, evt := range backfilled {
+		b.Ack(evt)
+	}
+	if saved, _, _ := cs.Load(); saved != 3 {
+		t.Fatalf("want checkpoint 3, got %d", saved)
+	}
+}
+
+func TestAddContractFromCallbackWithFullChannel(t *testing.T) {
+	second := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	fc := newFakeChain()
+	for i := int64(1); i <= 5; i++ {
+		lg := pingLog(i * 10)
+		lg.Address = second
+		fc.addBlock(pingLog(i), lg)
+	}
+	dataCh := make(chan Event, 1)
+	b := newTestBuilder(t, fc, dataCh)
+
+	from := uint64(1)
+	evts := consumeDuring(t, b, dataCh, func() {
+		if err := b.AddContract(context.Background(), second, testAbi, &from, "Ping"); err != nil {
+			t.Error(err)
+		}
+	})
+	added := 0
+	for _, evt := range evts {
+		if evt.Address == second {
+			added++
+		}
+	}
+	if len(evts) < 10 || added < 5 {
+		t.Fatalf("want the events of both contracts, got %d of %d for the added one", added, len(evts))
+	}
+}
@@ -0,0 +1,196 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	redo2 "github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/redo"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAbi = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"value","type":"uint256"}],"name":"Ping","type":"event"}]`
+
+var (
+	testContract = common.HexToAddress("0x5aeda56215b167893e80b4fe645ba6d5bab767de")
+	pingID       = crypto.Keccak256Hash([]byte("Ping(uint256)"))
+)
+
+// fakeChain is an in-memory chain whose tip can be replaced to simulate reorganizations.
+type fakeChain struct {
+	mu      sync.Mutex
+	headers []*types.Header
+	byHash  map[common.Hash]*types.Header
+	logs    map[common.Hash][]types.Log
+	salt    int64
+	// maxResults makes FilterLogs fail like providers that cap the result count
+	maxResults    int
+	queries       []ethereum.FilterQuery
+	headerQueries int
+	// latency is the round trip of every FilterLogs and HeaderByHash call
+	latency time.Duration
+}
+
+func newFakeChain() *fakeChain {
+	fc := &fakeChain{logs: make(map[common.Hash][]types.Log), byHash: make(map[common.Hash]*types.Header)}
+	genesis := &types.Header{Number: big.NewInt(0)}
+	fc.headers = append(fc.headers, genesis)
+	fc.byHash[genesis.Hash()] = genesis
+	return fc
+}
+
+func (fc *fakeChain) addBlock(logs ...types.Log) *types.Header {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	parent := fc.headers[len(fc.headers)-1]
+	fc.salt++
+	header := &types.Header{
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		ParentHash: parent.Hash(),
+		Time:       uint64(1500000000 + len(fc.headers)),
+		Extra:      big.NewInt(fc.salt).Bytes(),
+	}
+	hash := header.Hash()
+	for i := range logs {
+		logs[i].BlockNumber = header.Number.Uint64()
+		logs[i].BlockHash = hash
+		logs[i].TxHash = common.BigToHash(big.NewInt(fc.salt*1000 + int64(i)))
+		logs[i].Index = uint(i)
+	}
+	fc.headers = append(fc.headers, header)
+	fc.byHash[hash] = header
+	fc.logs[hash] = logs
+	return header
+}
+
+// truncate drops every block after number, new blocks are then built on top of it.
+func (fc *fakeChain) truncate(number uint64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.headers = fc.headers[:number+1]
+}
+
+func (fc *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if number == nil {
+		return fc.headers[len(fc.headers)-1], nil
+	}
+	if number.Uint64() >= uint64(len(fc.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return fc.headers[number.Uint64()], nil
+}
+
+func (fc *fakeChain) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	time.Sleep(fc.latency)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.headerQueries++
+	// blocks dropped by truncate are not found any more
+	header, ok := fc.byHash[hash]
+	if !ok || header.Number.Uint64() >= uint64(len(fc.headers)) || fc.headers[header.Number.Uint64()] != header {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (fc *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	time.Sleep(fc.latency)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.queries = append(fc.queries, q)
+	var logs []types.Log
+	for n := q.FromBlock.Uint64(); n <= q.ToBlock.Uint64() && n < uint64(len(fc.headers)); n++ {
+		for _, lg := range fc.logs[fc.headers[n].Hash()] {
+			if matchLog(lg, q) {
+				logs = append(logs, lg)
+			}
+		}
+	}
+	if fc.maxResults > 0 && len(logs) > fc.maxResults {
+		return nil, fmt.Errorf("query returned more than %d results", fc.maxResults)
+	}
+	return logs, nil
+}
+
+func matchLog(lg types.Log, q ethereum.FilterQuery) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, addr := range q.Addresses {
+			found = found || addr == lg.Address
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, topics := range q.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		if i >= len(lg.Topics) {
+			return false
+		}
+		found := false
+		for _, topic := range topics {
+			found = found || topic == lg.Topics[i]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func pingLog(value int64) types.Log {
+	return types.Log{
+		Address: testContract,
+		Topics:  []common.Hash{pingID},
+		Data:    common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+	}
+}
+
+func newTestBuilder(t testing.TB, conn ChainReader, dataCh chan Event) *Builder {
+	b := NewScanBuilder().
+		SetClient(conn).
+		SetContract(testContract, testAbi, "Ping").
+		SetDataChan(dataCh, make(chan error, 100)).
+		SetFrom(1)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// scanUntilIdle runs scan passes until the scanner waits for new blocks.
+func scanUntilIdle(es *eventScanner) {
+	for {
+		ctx := &redo2.RedoCtx{}
+		ctx.SetDelayBeforeNext(time.Second)
+		es.scan(ctx)
+		if ctx.DelayBeforeNext() != 0 {
+			return
+		}
+	}
+}
+
+func drain(ch chan Event) []Event {
+	var evts []Event
+	for {
+		select {
+		case evt := <-ch:
+			evts = append(evts, evt)
+		default:
+			return evts
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"sync"
+)
+
+const defaultHeaderCacheSize = 256
+
+// headerCache keeps the latest headers by hash, so all logs of a block share one fetch.
+type headerCache struct {
+	size    int
+	headers map[common.Hash]*types.Header
+	order   []common.Hash
+	mu      sync.Mutex
+}
+
+func newHeaderCache(size int) *headerCache {
+	return &headerCache{
+		size:    size,
+		headers: make(map[common.Hash]*types.Header, size),
+	}
+}
+
+func (hc *headerCache) get(hash common.Hash) (*types.Header, bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	header, ok := hc.headers[hash]
+	return header, ok
+}
+
+func (hc *headerCache) add(header *types.Header) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hash := header.Hash()
+	if _, ok := hc.headers[hash]; ok {
+		return
+	}
+	if len(hc.order) >= hc.size {
+		delete(hc.headers, hc.order[0])
+		hc.order = hc.order[1:]
+	}
+	hc.headers[hash] = header
+	hc.order = append(hc.order, hash)
+}
+
+func (es *eventScanner) header(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if header, ok := es.headers.get(hash); ok {
+		return header, nil
+	}
+	header, err := es.conn.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	es.headers.add(header)
+	return header, nil
+}
@@ -0,0 +1,53 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+)
+
+func TestEventCarriesLogPosition(t *testing.T) {
+	fc := newFakeChain()
+	fc.addBlock()
+	header := fc.addBlock(pingLog(1), pingLog(2), pingLog(3))
+	for i := 0; i < 100; i++ {
+		fc.addBlock()
+	}
+	dataCh := make(chan Event, 10)
+	b := newTestBuilder(t, fc, dataCh)
+
+	scanUntilIdle(b.es)
+	evts := drain(dataCh)
+	if len(evts) != 3 {
+		t.Fatalf("expect 3 events, got %d", len(evts))
+	}
+	for i, evt := range evts {
+		if evt.BlockHash != header.Hash() || evt.LogIndex != uint(i) || evt.Timestamp != header.Time || evt.Removed {
+			t.Errorf("event %d: unexpected %v, timestamp %d", i, evt, evt.Timestamp)
+		}
+	}
+	if fc.headerQueries != 1 {
+		t.Errorf("expect one header fetch for the block, got %d", fc.headerQueries)
+	}
+}
+
+func TestHeaderCacheIsBounded(t *testing.T) {
+	hc := newHeaderCache(2)
+	var headers []*types.Header
+	for i := int64(0); i < 3; i++ {
+		header := &types.Header{Number: big.NewInt(i)}
+		headers = append(headers, header)
+		hc.add(header)
+	}
+	if _, ok := hc.get(headers[0].Hash()); ok {
+		t.Error("the oldest header should be evicted")
+	}
+	for _, header := range headers[1:] {
+		if _, ok := hc.get(header.Hash()); !ok {
+			t.Errorf("header %v should be cached", header.Number)
+		}
+	}
+}
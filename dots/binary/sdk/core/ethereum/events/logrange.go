@@ -0,0 +1,81 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var resultLimitRegexp = regexp.MustCompile(`more than (\d+) results`)
+
+func (es *eventScanner) filterQuery(from uint64, to uint64) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: es.Contracts.Contracts(),
+		Topics:    [][]common.Hash{es.topics},
+	}
+}
+
+// filterLogs queries [from, to], the range is bisected while the node refuses it as too large,
+// the returned block is the end of the range that was really queried.
+func (es *eventScanner) filterLogs(from uint64, to uint64) ([]types.Log, uint64, error) {
+	if to > from+es.step {
+		to = from + es.step
+	}
+	for {
+		logs, err := es.conn.FilterLogs(context.Background(), es.filterQuery(from, to))
+		if err == nil {
+			es.growStep(to-from, len(logs))
+			return logs, to, nil
+		}
+		if !isRangeTooLarge(err) || to == from {
+			return nil, to, err
+		}
+		if m := resultLimitRegexp.FindStringSubmatch(err.Error()); m != nil {
+			if limit, e := strconv.Atoi(m[1]); e == nil {
+				es.resultLimit = limit
+			}
+		}
+		to = from + (to-from)/2
+		es.step = to - from
+	}
+}
+
+// growStep doubles the step again after a full sized range came back sparse.
+func (es *eventScanner) growStep(span uint64, count int) {
+	es.step = grownStep(es.step, es.StepLength, span, count, es.resultLimit)
+}
+
+// grownStep is step doubled up to max when a range of span blocks returned count logs,
+// resultLimit 0 means the limit of the node is unknown.
+func grownStep(step uint64, max uint64, span uint64, count int, resultLimit int) uint64 {
+	if span < step || step >= max {
+		return step
+	}
+	if resultLimit > 0 && count*2 >= resultLimit {
+		return step
+	}
+	if step = step*2 + 1; step > max {
+		step = max
+	}
+	return step
+}
+
+func isRangeTooLarge(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, key := range []string{"more than", "limit exceeded", "size exceeded", "too many", "range too large", "range is too large"} {
+		if strings.Contains(msg, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"testing"
+)
+
+func TestFilterQueryHasEventTopics(t *testing.T) {
+	fc := newFakeChain()
+	pong := pingLog(1)
+	pong.Topics = []common.Hash{crypto.Keccak256Hash([]byte("Pong(uint256)"))}
+	fc.addBlock(pingLog(1), pong)
+	b := newTestBuilder(t, fc, make(chan Event, 10))
+
+	logs, _, err := b.es.filterLogs(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expect only the Ping log from the node, got %d logs", len(logs))
+	}
+	if topics := fc.queries[0].Topics; len(topics) != 1 || len(topics[0]) != 1 || topics[0][0] != pingID {
+		t.Errorf("unexpected topics %v", topics)
+	}
+}
+
+func TestFilterLogsSplitsAndGrowsRange(t *testing.T) {
+	fc := newFakeChain()
+	for i := int64(1); i <= 8; i++ {
+		fc.addBlock(pingLog(i), pingLog(i))
+	}
+	for i := 0; i < 64; i++ {
+		fc.addBlock()
+	}
+	fc.maxResults = 4
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fc, dataCh).SetStep(15)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	scanUntilIdle(b.es)
+	if evts := drain(dataCh); len(evts) != 16 {
+		t.Fatalf("expect 16 events, got %d", len(evts))
+	}
+	if b.es.resultLimit != 4 {
+		t.Errorf("expect the result limit learned from the error, got %d", b.es.resultLimit)
+	}
+	if b.es.step != b.es.StepLength {
+		t.Errorf("expect the step to grow back to %d on sparse blocks, got %d", b.es.StepLength, b.es.step)
+	}
+}
+
+func TestIsRangeTooLarge(t *testing.T) {
+	cases := []struct {
+		msg    string
+		expect bool
+	}{
+		{"query returned more than 10000 results", true},
+		{"Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range", true},
+		{"query timeout exceeded", false},
+		{"connection refused", false},
+	}
+	for _, c := range cases {
+		if got := isRangeTooLarge(errors.New(c.msg)); got != c.expect {
+			t.Errorf("%q: expect %v, got %v", c.msg, c.expect, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// EventFilter keeps the events it returns true for, nil keeps every event.
+type EventFilter func(event Event) bool
+
+// QueryEvents returns the events of the configured contracts in [from, to] ordered by block and log index,
+// to 0 means the newest block below the margin. Empty names query the events the scanner was built with,
+// other events of the abi can be named too. The live scanner is not touched, its position,
+// subscription and callbacks stay as they are. Logs that can not be decoded are skipped.
+func (b *Builder) QueryEvents(ctx context.Context, from uint64, to uint64, names []string, filter EventFilter) ([]Event, error) {
+	es := b.es
+	// contracts added or removed later do not change a running query
+	es.mu.Lock()
+	contracts, built := es.Contracts, es.built
+	es.mu.Unlock()
+	if !built {
+		return nil, errors.New("scanner is not built")
+	}
+	wanted, topics, err := contracts.queryTopics(names)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		if to, err = es.NewestBlockNumber(); err != nil {
+			return nil, err
+		}
+	}
+
+	step := es.StepLength
+	var result []Event
+	for from <= to {
+		end := to
+		if end > from+step {
+			end = from + step
+		}
+		var logs []types.Log
+		asked := end
+		if logs, end, err = es.queryLogs(ctx, contracts, from, end, topics); err != nil {
+			return nil, fmt.Errorf("filter log(%v,%v) err:%v", from, end, err)
+		}
+		// the step follows the node like the live scan, without touching the live step
+		if end < asked {
+			if step = end - from; step < 1 {
+				step = 1
+			}
+		} else {
+			step = grownStep(step, es.StepLength, end-from, len(logs), 0)
+		}
+		for _, lg := range logs {
+			if lg.Removed {
+				continue
+			}
+			event, ok, _ := contracts.decodeLog(lg)
+			if !ok || !wanted(event.Address, event.Name) {
+				continue
+			}
+			header, err := es.header(ctx, lg.BlockHash)
+			if err != nil {
+				return nil, fmt.Errorf("query header %s fail:%v", lg.BlockHash.Hex(), err)
+			}
+			event.Timestamp = header.Time
+			if filter == nil || filter(event) {
+				result = append(result, event)
+			}
+		}
+		from = end + 1
+	}
+	return result, nil
+}
+
+// queryLogs is filterLogs without learning, the live scanner keeps its step and result limit.
+func (es *eventScanner) queryLogs(ctx context.Context, contracts contractMap, from uint64, to uint64, topics []common.Hash) ([]types.Log, uint64, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, to, err
+		}
+		logs, err := es.conn.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: contracts.Contracts(),
+			Topics:    [][]common.Hash{topics},
+		})
+		if err == nil {
+			return logs, to, nil
+		}
+		if !isRangeTooLarge(err) || to == from {
+			return nil, to, err
+		}
+		to = from + (to-from)/2
+	}
+}
+
+// queryTopics resolves the queried names to topic ids, wanted tells whether a decoded event was asked for.
+func (cm contractMap) queryTopics(names []string) (wanted func(addr common.Address, name string) bool, topics []common.Hash, err error) {
+	if len(names) == 0 {
+		topics, err = cm.Topics()
+		wanted = func(addr common.Address, name string) bool {
+			meta, _ := cm.GetMeta(addr)
+			return meta.HasEvent(name)
+		}
+		return
+	}
+
+	seen := make(map[common.Hash]bool)
+	asked := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, meta := range cm {
+			if evt, ok := meta.abi.Events[name]; ok {
+				found = true
+				if id := evt.Id(); !seen[id] {
+					seen[id] = true
+					topics = append(topics, id)
+				}
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("event %s is not in the abi of any contract", name)
+		}
+		asked[name] = true
+	}
+	wanted = func(_ common.Address, name string) bool {
+		return asked[name]
+	}
+	return
+}
@@ -0,0 +1,99 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func TestQueryEventsLeavesLiveScannerAlone(t *testing.T) {
+	fc := newFakeChain()
+	for i := int64(1); i <= 6; i++ {
+		fc.addBlock(pingLog(i), pingLog(i*10))
+	}
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fc, dataCh)
+	scanUntilIdle(b.es)
+	drain(dataCh)
+	from, step := b.es.From, b.es.step
+	fc.maxResults = 3
+
+	evts, err := b.QueryEvents(context.Background(), 2, 5, nil, func(evt Event) bool {
+		return evt.Data.Get("value").(*big.Int).Int64() < 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 4 {
+		t.Fatalf("want 4 events, got %d", len(evts))
+	}
+	for i, evt := range evts {
+		if evt.BlockNumber != uint64(i+2) || evt.Timestamp == 0 {
+			t.Errorf("unexpected event %v", evt)
+		}
+	}
+
+	if b.es.From != from || b.es.step != step || b.es.resultLimit != 0 {
+		t.Error("query should not change the live scanner")
+	}
+	if got := drain(dataCh); len(got) != 0 {
+		t.Errorf("query should not deliver to live callbacks, got %d events", len(got))
+	}
+}
+
+func TestQueryEventsUnknownName(t *testing.T) {
+	fc := newFakeChain()
+	b := newTestBuilder(t, fc, make(chan Event, 10))
+	if _, err := b.QueryEvents(context.Background(), 1, 1, []string{"Pong"}, nil); err == nil {
+		t.Error("want error for an event not in the abi")
+	}
+}
+
+func TestQueryEventsCancelled(t *testing.T) {
+	fc := newFakeChain()
+	fc.addBlock(pingLog(1))
+	b := newTestBuilder(t, fc, make(chan Event, 10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.QueryEvents(ctx, 1, 1, nil, nil); err == nil {
+		t.Error("want error for a cancelled query")
+	}
+}
+
+func TestQueryEventsBeforeBuild(t *testing.T) {
+	b := NewScanBuilder().SetClient(newFakeChain()).SetContract(testContract, testAbi, "Ping")
+	if _, err := b.QueryEvents(context.Background(), 1, 1, nil, nil); err == nil {
+		t.Error("want error for a scanner that is not built")
+	}
+}
+
+func TestQueryEventsRegrowsStep(t *testing.T) {
+	fc := newFakeChain()
+	// two logs in neighbouring blocks force a bisection down to a single block
+	fc.addBlock(pingLog(1))
+	fc.addBlock(pingLog(2))
+	for i := 0; i < 400; i++ {
+		fc.addBlock()
+	}
+	fc.addBlock(pingLog(3))
+	b := newTestBuilder(t, fc, make(chan Event, 10)).SetStep(100)
+	if err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	fc.maxResults = 1
+	fc.queries = nil
+
+	evts, err := b.QueryEvents(context.Background(), 1, 403, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 3 || evts[2].BlockNumber != 403 {
+		t.Fatalf("want the 3 events, got %v", evts)
+	}
+	if len(fc.queries) > 40 {
+		t.Errorf("want the step to grow again after the bisection, got %d queries", len(fc.queries))
+	}
+}
@@ -0,0 +1,88 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"sync"
+)
+
+// eventQueue hands the events over to the data channel on its own goroutine,
+// so the scanner never waits for a full channel while it holds es.mu and a callback
+// can call back into the builder. The scanner waits for the queue after it released es.mu.
+type eventQueue struct {
+	out     chan<- Event
+	events  []Event
+	sending bool
+	closed  bool
+	quit    chan struct{}
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+func newEventQueue(out chan<- Event) *eventQueue {
+	q := &eventQueue{out: out, quit: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// push never blocks.
+func (q *eventQueue) push(evt Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.events = append(q.events, evt)
+	q.cond.Broadcast()
+}
+
+// drain waits until every queued event is in the data channel or the queue is closed.
+func (q *eventQueue) drain() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && (len(q.events) > 0 || q.sending) {
+		q.cond.Wait()
+	}
+}
+
+// close drops the events not sent yet, they are not acknowledged and scanned again after a restart.
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed, q.events = true, nil
+	close(q.quit)
+	q.cond.Broadcast()
+}
+
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		for !q.closed && len(q.events) == 0 {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		evt := q.events[0]
+		q.events[0] = Event{}
+		q.events = q.events[1:]
+		q.sending = true
+		q.mu.Unlock()
+
+		select {
+		case q.out <- evt:
+		case <-q.quit:
+		}
+
+		q.mu.Lock()
+		q.sending = false
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,52 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// consumeDuring reads the data channel, calls f after the first event and returns once the scanner is idle.
+func consumeDuring(t *testing.T, b *Builder, dataCh chan Event, f func()) []Event {
+	done := make(chan struct{})
+	go func() {
+		scanUntilIdle(b.es)
+		close(done)
+	}()
+	var evts []Event
+	for {
+		select {
+		case evt := <-dataCh:
+			if len(evts) == 0 {
+				f()
+			}
+			evts = append(evts, evt)
+		case <-done:
+			return append(evts, drain(dataCh)...)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("scanner blocked after %d events", len(evts))
+		}
+	}
+}
+
+func TestCallbackCallsBuilderWithFullChannel(t *testing.T) {
+	fc := newFakeChain()
+	for i := int64(1); i <= 5; i++ {
+		fc.addBlock(pingLog(i))
+	}
+	dataCh := make(chan Event, 1)
+	b := newTestBuilder(t, fc, dataCh)
+
+	evts := consumeDuring(t, b, dataCh, func() {
+		b.Rewind(1)
+		if _, err := b.QueryEvents(context.Background(), 1, 5, nil, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	if len(evts) < 5 {
+		t.Fatalf("want every event after the rewind, got %d", len(evts))
+	}
+}
@@ -0,0 +1,139 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+)
+
+const defaultReorgWindow = 64
+
+type blockRecord struct {
+	hash   common.Hash
+	events []Event
+}
+
+// blockTracker remembers the hash and the delivered events of recently processed blocks.
+type blockTracker struct {
+	window  uint64
+	records map[uint64]*blockRecord
+}
+
+func newBlockTracker(window uint64) *blockTracker {
+	return &blockTracker{
+		window:  window,
+		records: make(map[uint64]*blockRecord),
+	}
+}
+
+func (bt *blockTracker) record(number uint64, hash common.Hash) {
+	if bt.window == 0 {
+		return
+	}
+	rec, ok := bt.records[number]
+	if !ok || rec.hash != hash {
+		rec = &blockRecord{hash: hash}
+		bt.records[number] = rec
+	}
+}
+
+func (bt *blockTracker) addEvent(hash common.Hash, evt Event) {
+	if bt.window == 0 {
+		return
+	}
+	bt.record(evt.BlockNumber, hash)
+	rec := bt.records[evt.BlockNumber]
+	rec.events = append(rec.events, evt)
+}
+
+func (bt *blockTracker) hash(number uint64) (common.Hash, bool) {
+	rec, ok := bt.records[number]
+	if !ok {
+		return common.Hash{}, false
+	}
+	return rec.hash, true
+}
+
+// numbers returns the remembered block numbers, newest first.
+func (bt *blockTracker) numbers() []uint64 {
+	nums := make([]uint64, 0, len(bt.records))
+	for n := range bt.records {
+		nums = append(nums, n)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] > nums[j] })
+	return nums
+}
+
+func (bt *blockTracker) prune(newest uint64) {
+	for n := range bt.records {
+		if n+bt.window <= newest {
+			delete(bt.records, n)
+		}
+	}
+}
+
+// rewind forgets every block after fork and returns their events newest first.
+func (bt *blockTracker) rewind(fork uint64) []Event {
+	var removed []Event
+	for _, n := range bt.numbers() {
+		if n <= fork {
+			break
+		}
+		evts := bt.records[n].events
+		for i := len(evts) - 1; i >= 0; i-- {
+			removed = append(removed, evts[i])
+		}
+		delete(bt.records, n)
+	}
+	return removed
+}
+
+// detectReorg compares the parent hash of the next block with the remembered hash,
+// on a mismatch it walks back to the newest remembered block that is still canonical.
+func (es *eventScanner) detectReorg() (fork uint64, reorged bool, err error) {
+	if es.From == 0 {
+		return 0, false, nil
+	}
+	parent, ok := es.tracker.hash(es.From - 1)
+	if !ok {
+		return 0, false, nil
+	}
+	header, err := es.conn.HeaderByNumber(context.Background(), new(big.Int).SetUint64(es.From))
+	if err != nil {
+		return 0, false, err
+	}
+	if header.ParentHash == parent {
+		return 0, false, nil
+	}
+
+	nums := es.tracker.numbers()
+	for _, n := range nums {
+		header, err := es.conn.HeaderByNumber(context.Background(), new(big.Int).SetUint64(n))
+		if err != nil {
+			return 0, false, err
+		}
+		if hash, _ := es.tracker.hash(n); header.Hash() == hash {
+			return n, true, nil
+		}
+	}
+	// the fork is older than the window, rescan everything remembered
+	if oldest := nums[len(nums)-1]; oldest > 0 {
+		return oldest - 1, true, nil
+	}
+	return 0, true, nil
+}
+
+// rewind retracts the events after fork and lets the next pass deliver the canonical logs.
+func (es *eventScanner) rewind(fork uint64) {
+	for _, evt := range es.tracker.rewind(fork) {
+		evt.Removed = true
+		es.sendData(evt)
+	}
+	es.From = fork + 1
+	es.next = logPosition{block: es.From}
+	es.rewindCheckpoint(fork)
+}
@@ -0,0 +1,67 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestScanRetractsReorganizedEvents(t *testing.T) {
+	fc := newFakeChain()
+	for i := int64(1); i <= 5; i++ {
+		fc.addBlock(pingLog(i))
+	}
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fc, dataCh)
+
+	scanUntilIdle(b.es)
+	if evts := drain(dataCh); len(evts) != 5 {
+		t.Fatalf("expect 5 events before reorg, got %d", len(evts))
+	}
+
+	fc.truncate(3)
+	fc.addBlock(pingLog(40))
+	fc.addBlock()
+	fc.addBlock(pingLog(60))
+	scanUntilIdle(b.es)
+
+	expects := []struct {
+		value   int64
+		removed bool
+	}{
+		{5, true},
+		{4, true},
+		{40, false},
+		{60, false},
+	}
+	evts := drain(dataCh)
+	if len(evts) != len(expects) {
+		t.Fatalf("expect %d events after reorg, got %d: %v", len(expects), len(evts), evts)
+	}
+	for i, exp := range expects {
+		value := evts[i].Data.Get("value").(*big.Int)
+		if value.Int64() != exp.value || evts[i].Removed != exp.removed {
+			t.Errorf("event %d: expect value %d removed %v, got %v", i, exp.value, exp.removed, evts[i])
+		}
+	}
+}
+
+func TestScanIgnoresReorgOutsideProcessedBlocks(t *testing.T) {
+	fc := newFakeChain()
+	for i := int64(1); i <= 3; i++ {
+		fc.addBlock(pingLog(i))
+	}
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fc, dataCh)
+	scanUntilIdle(b.es)
+	drain(dataCh)
+
+	fc.addBlock(pingLog(4))
+	scanUntilIdle(b.es)
+	evts := drain(dataCh)
+	if len(evts) != 1 || evts[0].Removed {
+		t.Fatalf("expect only the new event, got %v", evts)
+	}
+}
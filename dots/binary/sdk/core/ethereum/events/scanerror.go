@@ -0,0 +1,69 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type ErrorKind int
+
+const (
+	// ErrorRPC is a failed node request, the scanner retries it on the next pass.
+	ErrorRPC ErrorKind = iota
+	// ErrorDecode is a log of a known event that could not be decoded, the log is skipped.
+	ErrorDecode
+	// ErrorUnknownEvent is a log whose topic matches no event of the contract abi.
+	ErrorUnknownEvent
+	// ErrorCheckpoint is a failed checkpoint save.
+	ErrorCheckpoint
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorRPC:
+		return "rpc"
+	case ErrorDecode:
+		return "decode"
+	case ErrorUnknownEvent:
+		return "unknown-event"
+	case ErrorCheckpoint:
+		return "checkpoint"
+	default:
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+}
+
+// ScanError is what the scanner sends on its error channel.
+type ScanError struct {
+	Kind  ErrorKind
+	Block uint64
+	// TxHash is empty for errors not caused by a single log
+	TxHash common.Hash
+	// Dropped counts the errors before this one that were discarded because the channel was full
+	Dropped int
+	Err     error
+}
+
+func (se *ScanError) Error() string {
+	return fmt.Sprintf("%s error at block %d: %v", se.Kind, se.Block, se.Err)
+}
+
+// sendErr never blocks the scan, errors nobody reads in time are counted and dropped.
+// Ack calls it outside es.mu, errMu guards the count.
+func (es *eventScanner) sendErr(se *ScanError) {
+	if es.ErrChan == nil || se == nil {
+		return
+	}
+	es.errMu.Lock()
+	defer es.errMu.Unlock()
+	se.Dropped = es.droppedErrs
+	select {
+	case es.ErrChan <- se:
+		es.droppedErrs = 0
+	default:
+		es.droppedErrs++
+	}
+}
@@ -0,0 +1,70 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"testing"
+)
+
+// brokenChain fails every log query.
+type brokenChain struct {
+	*fakeChain
+}
+
+func (bc *brokenChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSendErrNeverBlocks(t *testing.T) {
+	fc := newFakeChain()
+	fc.addBlock(pingLog(1))
+	errCh := make(chan error, 1)
+	b := newTestBuilder(t, &brokenChain{fc}, make(chan Event, 10))
+	b.es.ErrChan = errCh
+
+	// every pass fails, the channel is never read
+	for i := 0; i < 5; i++ {
+		b.es.scan(nil)
+	}
+
+	se, ok := (<-errCh).(*ScanError)
+	if !ok || se.Kind != ErrorRPC {
+		t.Fatalf("want a rpc scan error, got %v", se)
+	}
+	b.es.scan(nil)
+	se = (<-errCh).(*ScanError)
+	if se.Dropped != 4 {
+		t.Errorf("want 4 dropped errors, got %d", se.Dropped)
+	}
+}
+
+func TestDecodeAndUnknownEventErrors(t *testing.T) {
+	fc := newFakeChain()
+	errCh := make(chan error, 10)
+	b := newTestBuilder(t, fc, make(chan Event, 10))
+	b.es.ErrChan = errCh
+
+	broken := pingLog(1)
+	broken.Data = broken.Data[:10]
+	unknown := pingLog(2)
+	unknown.Topics = []common.Hash{common.HexToHash("0x01")}
+	fc.addBlock(broken, unknown)
+	b.es.next = logPosition{}
+	for _, lg := range fc.logs[fc.headers[1].Hash()] {
+		b.es.deliverLog(lg)
+	}
+
+	kinds := []ErrorKind{ErrorDecode, ErrorUnknownEvent}
+	for _, kind := range kinds {
+		se := (<-errCh).(*ScanError)
+		if se.Kind != kind || se.Block != 1 || se.TxHash == (common.Hash{}) {
+			t.Errorf("want %s error at block 1 with tx, got %v", kind, se)
+		}
+	}
+}
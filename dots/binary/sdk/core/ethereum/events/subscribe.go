@@ -0,0 +1,234 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sort"
+)
+
+const pushBufferSize = 128
+
+// LogSubscriber is implemented by ethclient.Client over websocket and IPC endpoints.
+type LogSubscriber interface {
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
+// logPosition is the first log that has not been delivered yet.
+type logPosition struct {
+	block uint64
+	index uint
+}
+
+func (lp logPosition) before(lg types.Log) bool {
+	return lg.BlockNumber > lp.block || (lg.BlockNumber == lp.block && lg.Index >= lp.index)
+}
+
+// trySubscribe is called with es.mu held once polling caught up with the newest block.
+func (es *eventScanner) trySubscribe() {
+	if !es.push || es.sub != nil || es.To > 0 {
+		return
+	}
+	subscriber, ok := es.conn.(LogSubscriber)
+	if !ok {
+		return
+	}
+
+	ch := make(chan types.Log, pushBufferSize)
+	fq := es.filterQuery(0, 0)
+	fq.FromBlock, fq.ToBlock = nil, nil
+	sub, err := subscriber.SubscribeFilterLogs(context.Background(), fq, ch)
+	if err != nil {
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("subscribe logs fail:%v, keep polling", err)})
+		return
+	}
+
+	// polling stops at the margin, the blocks above it are queued like pushed logs
+	head, err := es.headBlockNumber()
+	var newest uint64
+	if err == nil && head > es.marginBlock {
+		newest = head - es.marginBlock
+	}
+	for err == nil && newest >= es.From {
+		var logs []types.Log
+		var to uint64
+		if logs, to, err = es.filterLogs(es.From, newest); err == nil {
+			for _, lg := range logs {
+				es.deliverLog(lg)
+			}
+			es.markCheckpoint(to)
+			es.From = to + 1
+			es.next = logPosition{block: es.From}
+		}
+	}
+	es.pushed = nil
+	for from := es.From; err == nil && from <= head; {
+		var logs []types.Log
+		var to uint64
+		if logs, to, err = es.filterLogs(from, head); err == nil {
+			for _, lg := range logs {
+				es.queuePushed(lg)
+			}
+			from = to + 1
+		}
+	}
+	if err != nil {
+		sub.Unsubscribe()
+		es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("backfill before subscription fail:%v, keep polling", err)})
+		return
+	}
+
+	es.sub, es.pushHead = sub, head
+	go es.receive(sub, ch)
+}
+
+func (es *eventScanner) receive(sub ethereum.Subscription, ch <-chan types.Log) {
+	for {
+		select {
+		case lg := <-ch:
+			es.mu.Lock()
+			if es.sub == sub {
+				es.handlePushedLog(lg)
+			}
+			es.mu.Unlock()
+			es.queue.drain()
+		case err, ok := <-sub.Err():
+			es.mu.Lock()
+			if es.sub == sub {
+				es.sub, es.pushed = nil, nil
+				if ok && err != nil {
+					es.sendErr(&ScanError{Kind: ErrorRPC, Block: es.From, Err: fmt.Errorf("log subscription dropped:%v, fall back to polling", err)})
+				}
+			}
+			es.mu.Unlock()
+			return
+		}
+	}
+}
+
+// handlePushedLog queues the log until it is margin blocks deep, a retracted log still queued is dropped.
+func (es *eventScanner) handlePushedLog(lg types.Log) {
+	if lg.Removed {
+		if !es.dropPushed(lg) {
+			es.retractLog(lg)
+		}
+		return
+	}
+	es.queuePushed(lg)
+	if lg.BlockNumber > es.pushHead {
+		head, err := es.headBlockNumber()
+		if err != nil {
+			es.sendErr(&ScanError{Kind: ErrorRPC, Block: lg.BlockNumber, Err: fmt.Errorf("query newest block number fail:%v, will retry later", err)})
+			return
+		}
+		es.pushHead = head
+	}
+	es.releasePushed(es.pushHead)
+}
+
+// releasePushed delivers the queued logs of the blocks margin blocks below head like polled logs,
+// the block is checked against the canonical header and remembered for reorg detection.
+// From stays on the block of the last delivered log, so polling after a dropped subscription
+// backfills from there and skips what was delivered. The quiet blocks up to the margin
+// move From and the checkpoint like a polled range without logs.
+func (es *eventScanner) releasePushed(head uint64) {
+	if head < es.marginBlock {
+		return
+	}
+	newest := head - es.marginBlock
+	released := false
+	for len(es.pushed) > 0 && es.pushed[0].BlockNumber <= newest {
+		number := es.pushed[0].BlockNumber
+		n := 1
+		for n < len(es.pushed) && es.pushed[n].BlockNumber == number {
+			n++
+		}
+		header, err := es.conn.HeaderByNumber(context.Background(), new(big.Int).SetUint64(number))
+		if err != nil {
+			es.sendErr(&ScanError{Kind: ErrorRPC, Block: number, Err: fmt.Errorf("query header err:%v, will retry later", err)})
+			return
+		}
+		logs := es.pushed[:n]
+		es.pushed = es.pushed[n:]
+
+		if number > es.From {
+			es.markCheckpoint(number - 1)
+			es.From = number
+		}
+		es.headers.add(header)
+		delivered := es.delivered
+		for _, lg := range logs {
+			// logs of a block that was reorganized meanwhile come again with the canonical block
+			if lg.BlockHash == header.Hash() {
+				es.deliverLog(lg)
+			}
+		}
+		es.tracker.record(number, header.Hash())
+		es.sendProgress(Progress{From: number, To: number, Head: head, Events: es.delivered - delivered})
+		released = number == newest
+	}
+	es.tracker.prune(newest)
+	if !released && newest >= es.From {
+		from := es.From
+		if es.next.block == from && es.next.index > 0 {
+			from++
+		}
+		es.markCheckpoint(newest)
+		es.From = newest + 1
+		es.next = logPosition{block: es.From}
+		if from <= newest {
+			es.sendProgress(Progress{From: from, To: newest, Head: head})
+		}
+	}
+}
+
+// queuePushed keeps the queue in log order, a log pushed twice is queued once.
+func (es *eventScanner) queuePushed(lg types.Log) {
+	i := sort.Search(len(es.pushed), func(i int) bool {
+		p := es.pushed[i]
+		return p.BlockNumber > lg.BlockNumber || p.BlockNumber == lg.BlockNumber && p.Index >= lg.Index
+	})
+	if i < len(es.pushed) && es.pushed[i].BlockHash == lg.BlockHash && es.pushed[i].Index == lg.Index {
+		return
+	}
+	es.pushed = append(es.pushed, types.Log{})
+	copy(es.pushed[i+1:], es.pushed[i:])
+	es.pushed[i] = lg
+}
+
+func (es *eventScanner) dropPushed(lg types.Log) bool {
+	for i, p := range es.pushed {
+		if p.BlockHash == lg.BlockHash && p.Index == lg.Index {
+			es.pushed = append(es.pushed[:i], es.pushed[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (es *eventScanner) retractLog(lg types.Log) {
+	rec, ok := es.tracker.records[lg.BlockNumber]
+	if ok && rec.hash == lg.BlockHash {
+		for i := len(rec.events) - 1; i >= 0; i-- {
+			evt := rec.events[i]
+			if evt.TxHash == lg.TxHash && evt.LogIndex == lg.Index {
+				evt.Removed = true
+				es.sendData(evt)
+				rec.events = append(rec.events[:i], rec.events[i+1:]...)
+				break
+			}
+		}
+	}
+	// the canonical logs of this block come with new indexes
+	if lg.BlockNumber <= es.next.block {
+		es.next = logPosition{block: lg.BlockNumber}
+		if lg.BlockNumber < es.From {
+			es.From = lg.BlockNumber
+		}
+	}
+}
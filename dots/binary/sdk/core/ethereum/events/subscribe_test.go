@@ -0,0 +1,191 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeSubscription struct {
+	errCh chan error
+}
+
+func (fs *fakeSubscription) Err() <-chan error {
+	return fs.errCh
+}
+
+func (fs *fakeSubscription) Unsubscribe() {}
+
+// fakePushChain hands out subscriptions whose logs are fed by the test.
+type fakePushChain struct {
+	*fakeChain
+	logCh chan<- types.Log
+	sub   *fakeSubscription
+}
+
+func (fp *fakePushChain) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	fp.logCh = ch
+	fp.sub = &fakeSubscription{errCh: make(chan error, 1)}
+	return fp.sub, nil
+}
+
+func waitEvents(t *testing.T, ch chan Event, n int) []Event {
+	var evts []Event
+	for len(evts) < n {
+		select {
+		case evt := <-ch:
+			evts = append(evts, evt)
+		case <-time.After(time.Second):
+			t.Fatalf("expect %d events, got %v", n, evts)
+		}
+	}
+	return evts
+}
+
+// waitPushed waits until n pushed logs wait for the margin.
+func waitPushed(es *eventScanner, n int) {
+	for {
+		es.mu.Lock()
+		queued := len(es.pushed)
+		es.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPushModeBackfillsAfterDroppedSubscription(t *testing.T) {
+	fp := &fakePushChain{fakeChain: newFakeChain()}
+	fp.addBlock(pingLog(1))
+	fp.addBlock(pingLog(2))
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fp, dataCh).SetPushMode(true)
+
+	scanUntilIdle(b.es)
+	waitEvents(t, dataCh, 2)
+	if fp.sub == nil {
+		t.Fatal("expect a log subscription once caught up")
+	}
+
+	header := fp.addBlock(pingLog(3), pingLog(4))
+	fp.logCh <- fp.logs[header.Hash()][0]
+	if evts := waitEvents(t, dataCh, 1); evts[0].Data.Get("value").(*big.Int).Int64() != 3 {
+		t.Fatalf("expect the pushed event, got %v", evts)
+	}
+
+	fp.sub.errCh <- ethereum.NotFound
+	for {
+		b.es.mu.Lock()
+		dropped := b.es.sub == nil
+		b.es.mu.Unlock()
+		if dropped {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	fp.sub = nil
+	fp.addBlock(pingLog(5))
+	scanUntilIdle(b.es)
+
+	evts := waitEvents(t, dataCh, 2)
+	for i, exp := range []int64{4, 5} {
+		if value := evts[i].Data.Get("value").(*big.Int).Int64(); value != exp {
+			t.Errorf("event %d: expect value %d, got %d", i, exp, value)
+		}
+	}
+	if len(drain(dataCh)) != 0 {
+		t.Error("pushed events must not be delivered twice")
+	}
+	if fp.sub == nil {
+		t.Error("expect the scanner to subscribe again after the backfill")
+	}
+}
+
+func TestPushModeWaitsForMargin(t *testing.T) {
+	fp := &fakePushChain{fakeChain: newFakeChain()}
+	fp.addBlock(pingLog(1))
+	fp.addBlock(pingLog(2))
+	fp.addBlock()
+	dataCh := make(chan Event, 100)
+	b := newTestBuilder(t, fp, dataCh).SetPushMode(true).SetBlockMargin(1)
+
+	scanUntilIdle(b.es)
+	waitEvents(t, dataCh, 2)
+	if fp.sub == nil {
+		t.Fatal("expect a log subscription once caught up")
+	}
+
+	header := fp.addBlock(pingLog(4))
+	fp.logCh <- fp.logs[header.Hash()][0]
+	waitPushed(b.es, 1)
+	retracted := fp.logs[header.Hash()][0]
+	retracted.Removed = true
+	fp.logCh <- retracted
+	waitPushed(b.es, 0)
+	reorged := fp.addBlock(pingLog(5))
+	fp.logCh <- fp.logs[reorged.Hash()][0]
+	waitPushed(b.es, 1)
+	if len(drain(dataCh)) != 0 {
+		t.Fatal("expect the pushed logs to wait for the margin")
+	}
+	fp.addBlock()
+	scanUntilIdle(b.es)
+
+	// the log of block 5 is one block deep, the retracted log of block 4 never comes
+	evts := waitEvents(t, dataCh, 1)
+	if value := evts[0].Data.Get("value").(*big.Int).Int64(); value != 5 {
+		t.Fatalf("expect the event of block 5, got %v", evts)
+	}
+	if len(drain(dataCh)) != 0 {
+		t.Error("expect no event of the retracted log")
+	}
+	b.es.mu.Lock()
+	defer b.es.mu.Unlock()
+	if hash, ok := b.es.tracker.hash(5); !ok || hash != reorged.Hash() {
+		t.Error("expect the pushed block remembered for reorg detection")
+	}
+}
+
+func TestPushModeMovesPastQuietBlocks(t *testing.T) {
+	fp := &fakePushChain{fakeChain: newFakeChain()}
+	fp.addBlock(pingLog(1))
+	dataCh := make(chan Event, 100)
+	progressCh := make(chan Progress, 100)
+	cs := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint"))
+	b := newTestBuilder(t, fp, dataCh).SetPushMode(true).SetCheckpointStore(cs).SetProgressChan(progressCh)
+
+	scanUntilIdle(b.es)
+	for _, evt := range waitEvents(t, dataCh, 1) {
+		b.Ack(evt)
+	}
+	if fp.sub == nil {
+		t.Fatal("expect a log subscription once caught up")
+	}
+	for len(progressCh) > 0 {
+		<-progressCh
+	}
+
+	// no log is pushed for the new blocks
+	fp.addBlock()
+	fp.addBlock()
+	scanUntilIdle(b.es)
+	if saved, _, _ := cs.Load(); saved != 3 {
+		t.Fatalf("expect checkpoint 3 after the quiet blocks, got %d", saved)
+	}
+	select {
+	case p := <-progressCh:
+		if p.From != 2 || p.To != 3 || p.Head != 3 || p.Events != 0 {
+			t.Fatalf("expect progress of the quiet blocks 2-3, got %+v", p)
+		}
+	default:
+		t.Fatal("expect progress while no log arrives")
+	}
+}
@@ -0,0 +1,109 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func mustType(t *testing.T, typ string) abi.Type {
+	parsed, err := abi.NewType(typ, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// twos returns the 256 bit two's complement topic of a signed value.
+func twos(v int64) common.Hash {
+	num := big.NewInt(v)
+	if v < 0 {
+		num.Add(num, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return common.BigToHash(num)
+}
+
+func TestReadTopic(t *testing.T) {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	largeAmount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	addr := common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+	strHash := crypto.Keccak256Hash([]byte("hello"))
+
+	var fixed4 [4]byte
+	copy(fixed4[:], []byte{0xde, 0xad, 0xbe, 0xef})
+	var fixedTopic common.Hash
+	copy(fixedTopic[:], fixed4[:])
+
+	cases := []struct {
+		typ    string
+		topic  common.Hash
+		expect interface{}
+	}{
+		{"bool", common.BigToHash(big.NewInt(1)), true},
+		{"bool", common.Hash{}, false},
+		{"uint8", common.BigToHash(big.NewInt(255)), uint8(255)},
+		{"uint16", common.BigToHash(big.NewInt(65535)), uint16(65535)},
+		{"uint32", common.BigToHash(big.NewInt(4294967295)), uint32(4294967295)},
+		{"uint64", common.BigToHash(new(big.Int).SetUint64(18446744073709551615)), uint64(18446744073709551615)},
+		{"int8", twos(-128), int8(-128)},
+		{"int16", twos(-2), int16(-2)},
+		{"int32", twos(-1), int32(-1)},
+		{"int64", twos(-9223372036854775808), int64(-9223372036854775808)},
+		{"int64", twos(42), int64(42)},
+		{"uint256", common.BigToHash(maxUint256), maxUint256},
+		{"uint256", common.BigToHash(largeAmount), largeAmount},
+		{"int256", twos(-1), big.NewInt(-1)},
+		{"int256", twos(7), big.NewInt(7)},
+		{"int24", twos(-5), big.NewInt(-5)},
+		{"address", addr.Hash(), addr},
+		{"bytes4", fixedTopic, fixed4},
+		{"bytes32", strHash, [32]byte(strHash)},
+		{"string", strHash, strHash},
+		{"bytes", strHash, strHash},
+		{"uint256[]", strHash, strHash},
+		{"address[2]", strHash, strHash},
+	}
+	for _, c := range cases {
+		got, err := readTopic(mustType(t, c.typ), c.topic)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("%s: expect %v (%T), got %v (%T)", c.typ, c.expect, c.expect, got, got)
+		}
+	}
+}
+
+func TestReadTopicErrors(t *testing.T) {
+	cases := []struct {
+		typ   string
+		topic common.Hash
+	}{
+		{"bool", common.BigToHash(big.NewInt(2))},
+		{"bool", common.BigToHash(new(big.Int).Lsh(big.NewInt(1), 100))},
+		{"function", common.BigToHash(big.NewInt(1))},
+	}
+	for _, c := range cases {
+		if _, err := readTopic(mustType(t, c.typ), c.topic); err == nil {
+			t.Errorf("%s %s: expect an error", c.typ, c.topic.Hex())
+		}
+	}
+}
+
+func TestParseTopicsFieldMismatch(t *testing.T) {
+	fields := abi.Arguments{{Name: "value", Type: mustType(t, "uint256"), Indexed: true}}
+	if err := parseTopics(NewJSONObj(), fields, nil); err == nil {
+		t.Error("expect an error when topics and fields don't match")
+	}
+	fields[0].Indexed = false
+	if err := parseTopics(NewJSONObj(), fields, []common.Hash{{}}); err == nil {
+		t.Error("expect an error for a non-indexed field")
+	}
+}
@@ -0,0 +1,152 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"reflect"
+)
+
+// typed events of the protocol and token contracts, fields are filled by the abi tag.
+// the scanner sets Event.Typed to a pointer of one of them, JSONObj stays the generic form.
+
+type DataPublish struct {
+	SeqNo         string           `abi:"seqNo"`
+	PublishId     string           `abi:"publishId"`
+	Price         *big.Int         `abi:"price"`
+	DespDataId    string           `abi:"despDataId"`
+	SupportVerify bool             `abi:"supportVerify"`
+	Users         []common.Address `abi:"users"`
+}
+
+type TransactionCreate struct {
+	SeqNo         string           `abi:"seqNo"`
+	TransactionId *big.Int         `abi:"transactionId"`
+	PublishId     string           `abi:"publishId"`
+	ProofIds      [][32]byte       `abi:"proofIds"`
+	NeedVerify    bool             `abi:"needVerify"`
+	State         uint8            `abi:"state"`
+	Users         []common.Address `abi:"users"`
+}
+
+type VerifiersChosen struct {
+	SeqNo         string           `abi:"seqNo"`
+	TransactionId *big.Int         `abi:"transactionId"`
+	PublishId     string           `abi:"publishId"`
+	ProofIds      [][32]byte       `abi:"proofIds"`
+	State         uint8            `abi:"state"`
+	Users         []common.Address `abi:"users"`
+}
+
+type Vote struct {
+	SeqNo         string           `abi:"seqNo"`
+	TransactionId *big.Int         `abi:"transactionId"`
+	Judge         bool             `abi:"judge"`
+	Comments      string           `abi:"comments"`
+	State         uint8            `abi:"state"`
+	Index         uint8            `abi:"index"`
+	Users         []common.Address `abi:"users"`
+}
+
+type Buy struct {
+	SeqNo               string           `abi:"seqNo"`
+	TransactionId       *big.Int         `abi:"transactionId"`
+	PublishId           string           `abi:"publishId"`
+	MetaDataIdEncSeller []byte           `abi:"metaDataIdEncSeller"`
+	State               uint8            `abi:"state"`
+	Buyer               common.Address   `abi:"buyer"`
+	Index               uint8            `abi:"index"`
+	Users               []common.Address `abi:"users"`
+}
+
+type ReadyForDownload struct {
+	SeqNo              string           `abi:"seqNo"`
+	TransactionId      *big.Int         `abi:"transactionId"`
+	MetaDataIdEncBuyer []byte           `abi:"metaDataIdEncBuyer"`
+	State              uint8            `abi:"state"`
+	Index              uint8            `abi:"index"`
+	Users              []common.Address `abi:"users"`
+}
+
+type TransactionClose struct {
+	SeqNo         string           `abi:"seqNo"`
+	TransactionId *big.Int         `abi:"transactionId"`
+	State         uint8            `abi:"state"`
+	Index         uint8            `abi:"index"`
+	Users         []common.Address `abi:"users"`
+}
+
+type RegisterVerifier struct {
+	SeqNo string           `abi:"seqNo"`
+	Users []common.Address `abi:"users"`
+}
+
+type VerifierDisable struct {
+	SeqNo    string           `abi:"seqNo"`
+	Verifier common.Address   `abi:"verifier"`
+	Users    []common.Address `abi:"users"`
+}
+
+type Approval struct {
+	Owner   common.Address `abi:"owner"`
+	Spender common.Address `abi:"spender"`
+	Value   *big.Int       `abi:"value"`
+}
+
+type Transfer struct {
+	From  common.Address `abi:"from"`
+	To    common.Address `abi:"to"`
+	Value *big.Int       `abi:"value"`
+}
+
+var typedEvents = map[string]func() interface{}{
+	"DataPublish":       func() interface{} { return new(DataPublish) },
+	"TransactionCreate": func() interface{} { return new(TransactionCreate) },
+	"VerifiersChosen":   func() interface{} { return new(VerifiersChosen) },
+	"Vote":              func() interface{} { return new(Vote) },
+	"Buy":               func() interface{} { return new(Buy) },
+	"ReadyForDownload":  func() interface{} { return new(ReadyForDownload) },
+	"TransactionClose":  func() interface{} { return new(TransactionClose) },
+	"RegisterVerifier":  func() interface{} { return new(RegisterVerifier) },
+	"VerifierDisable":   func() interface{} { return new(VerifierDisable) },
+	"Approval":          func() interface{} { return new(Approval) },
+	"Transfer":          func() interface{} { return new(Transfer) },
+}
+
+// DecodeTyped fills the typed struct of the event from its decoded data,
+// it returns nil without error for events that have no typed struct.
+func DecodeTyped(name string, data JSONObj) (interface{}, error) {
+	ctor, ok := typedEvents[name]
+	if !ok {
+		return nil, nil
+	}
+	typed := ctor()
+	v := reflect.ValueOf(typed).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		argName := field.Tag.Get("abi")
+		value, ok := data[argName]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing argument %s", name, argName)
+		}
+		if err := setTypedField(v.Field(i), value); err != nil {
+			return nil, fmt.Errorf("%s: argument %s: %v", name, argName, err)
+		}
+	}
+	return typed, nil
+}
+
+func setTypedField(field reflect.Value, value interface{}) error {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() {
+		return fmt.Errorf("nil value")
+	}
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return nil
+	}
+	return fmt.Errorf("can't assign %v to %v", rv.Type(), field.Type())
+}
@@ -0,0 +1,92 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package events
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const typedTestAbi = `[
+{"anonymous":false,"inputs":[{"indexed":false,"name":"seqNo","type":"string"},{"indexed":false,"name":"publishId","type":"string"},{"indexed":false,"name":"price","type":"uint256"},{"indexed":false,"name":"despDataId","type":"string"},{"indexed":false,"name":"supportVerify","type":"bool"},{"indexed":false,"name":"users","type":"address[]"}],"name":"DataPublish","type":"event"},
+{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Approval","type":"event"}
+]`
+
+func decodeTestLog(t *testing.T, lg types.Log) (string, JSONObj) {
+	parsed, err := abi.JSON(strings.NewReader(typedTestAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := NewJSONObj()
+	name, err := unpackMatchedLog(out, lg, &contractMeta{abi: &parsed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name, out
+}
+
+func TestDecodeTypedDataPublish(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(typedTestAbi))
+	evt := parsed.Events["DataPublish"]
+	user := common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+	data, err := evt.Inputs.Pack("Dapp", "pub-1", big.NewInt(1000), "desp", true, []common.Address{user})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, out := decodeTestLog(t, types.Log{Topics: []common.Hash{evt.Id()}, Data: data})
+	typed, err := DecodeTyped(name, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dp, ok := typed.(*DataPublish)
+	if !ok {
+		t.Fatalf("expect *DataPublish, got %T", typed)
+	}
+	if dp.SeqNo != "Dapp" || dp.PublishId != "pub-1" || dp.Price.Int64() != 1000 || dp.DespDataId != "desp" ||
+		!dp.SupportVerify || len(dp.Users) != 1 || dp.Users[0] != user {
+		t.Errorf("unexpected decoded event %+v", dp)
+	}
+}
+
+func TestDecodeTypedApproval(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(typedTestAbi))
+	evt := parsed.Events["Approval"]
+	owner := common.HexToAddress("0xd280b60c38bc8db9d309fa5a540ffec499f0a3e8")
+	spender := common.HexToAddress("0xbb7bae05bdbc0ed9e514ce18122fc6b4cbcca346")
+	data, err := abi.Arguments{evt.Inputs[2]}.Pack(big.NewInt(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, out := decodeTestLog(t, types.Log{
+		Topics: []common.Hash{evt.Id(), owner.Hash(), spender.Hash()},
+		Data:   data,
+	})
+	typed, err := DecodeTyped(name, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := typed.(*Approval)
+	if ap.Owner != owner || ap.Spender != spender || ap.Value.Int64() != 7 {
+		t.Errorf("unexpected decoded event %+v", ap)
+	}
+}
+
+func TestDecodeTypedRejectsMismatch(t *testing.T) {
+	data := NewJSONObj()
+	data.Set("owner", "not an address")
+	data.Set("spender", common.Address{})
+	data.Set("value", big.NewInt(1))
+	if _, err := DecodeTyped("Approval", data); err == nil {
+		t.Error("expect an error for a mismatched argument")
+	}
+	if typed, err := DecodeTyped("Ping", NewJSONObj()); typed != nil || err != nil {
+		t.Error("events without typed struct decode to nil")
+	}
+}
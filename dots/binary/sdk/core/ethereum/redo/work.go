@@ -30,6 +30,10 @@ func (ctx *RedoCtx) SetDelayBeforeNext(new_duration time.Duration) {
 	ctx.delayBeforeNextLoop = new_duration
 }
 
+func (ctx *RedoCtx) DelayBeforeNext() time.Duration {
+	return ctx.delayBeforeNextLoop
+}
+
 func (ctx *RedoCtx) StartNextRightNow() {
 	ctx.SetDelayBeforeNext(time.Duration(0))
 }
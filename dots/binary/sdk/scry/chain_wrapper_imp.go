@@ -4,33 +4,49 @@
 package scry
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/scryinfo/dot/dot"
 	"github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
 	"github.com/scryinfo/dp/dots/binary/sdk/interface/contract"
-	"github.com/scryinfo/dp/dots/binary/sdk/settings"
 	"github.com/scryinfo/dp/dots/binary/sdk/util/accounts"
 	"github.com/scryinfo/dp/util"
 	"go.uber.org/zap"
 	"math/big"
+	"sync"
 )
 
 type chainWrapperImp struct {
 	conn         *ethclient.Client
+	txBackend    TxBackend
+	transactor   *chainoperations.Transactor
+	appId        string
 	scryProtocol *contract.ScryProtocol
 	scryToken    *contract.ScryToken
+	txs          map[common.Hash]*TxHandle
+	// txOrder are the hashes of txs, oldest first
+	txOrder []common.Hash
+	txsMu   sync.Mutex
 }
 
+// maxTrackedTxs bounds the handles kept for TxByHash when nobody waits for them to resolve
+const maxTrackedTxs = 1024
+
 func NewChainWrapper(protocolContractAddress common.Address,
 	tokenContractAddress common.Address,
 	clientConn *ethclient.Client,
+	txBackend TxBackend,
+	transactor *chainoperations.Transactor,
+	appId string,
 ) (ChainWrapper, error) {
 	var err error = nil
-	c := &chainWrapperImp{}
+	c := &chainWrapperImp{appId: appId, txs: make(map[common.Hash]*TxHandle)}
 
 	c.scryProtocol, err = contract.NewScryProtocol(protocolContractAddress, clientConn)
 	if err != nil {
@@ -45,49 +61,53 @@ func NewChainWrapper(protocolContractAddress common.Address,
 	}
 
 	c.conn = clientConn
+	c.txBackend = txBackend
+	c.transactor = transactor
 
 	return c, err
 }
 
 func (c *chainWrapperImp) Publish(txParams *chainoperations.TransactParams, price *big.Int, metaDataID []byte,
-	proofDataIDs []string, proofNum int, detailsID string, supportVerify bool) (string, error) {
+	proofDataIDs []string, proofNum int, detailsID string, supportVerify bool) (publishId string, handle *TxHandle, err error) {
 	logger := dot.Logger()
 
 	defer func() {
 		if er := recover(); er != nil {
 			logger.Errorln("", zap.Any("failed to publish data, error:", er))
+			publishId, handle, err = "", nil, fmt.Errorf("failed to publish data: %v", er)
 		}
 	}()
 
 	//generate publishId
-	publishId := util.GenerateUUID()
+	publishId = util.GenerateUUID()
 
 	pdIDs := make([][32]byte, proofNum)
-	var err error = nil
 	for i := 0; i < proofNum; i++ {
 		pdIDs[i], err = ipfsHashToBytes32(proofDataIDs[i])
 		if err != nil {
 			logger.Errorln("failed to convert ipfs hash to bytes32")
-			return "", err
+			return "", nil, err
 		}
 	}
 
 	encMetaId, err := accounts.GetAMInstance().Encrypt(metaDataID, txParams.From.String())
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to encrypt meta data hash, error: ", err))
-		return "", err
+		return "", nil, err
 	}
 
-	tx, err := c.scryProtocol.PublishDataInfo(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), publishId, price,
-		encMetaId, pdIDs, detailsID, supportVerify)
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.PublishDataInfo(opts, c.appSeqNo(txParams), publishId, price,
+			encMetaId, pdIDs, detailsID, supportVerify)
+	})
 	if err != nil {
 		logger.Errorln("", zap.NamedError("failed to publish data information, error: ", err))
-		return "", err
+		return "", nil, err
 	}
 
 	logger.Debugln("publish transaction: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return publishId, nil
+	return publishId, c.track(tx), nil
 }
 
 func ipfsHashToBytes32(src string) ([32]byte, error) {
@@ -113,101 +133,131 @@ func Bytes32ToIpfsHash(value [32]byte) (string, error) {
 	return hash, nil
 }
 
-func (c *chainWrapperImp) PrepareToBuy(txParams *chainoperations.TransactParams, publishId string, startVerify bool) error {
+func (c *chainWrapperImp) PrepareToBuy(txParams *chainoperations.TransactParams, publishId string, startVerify bool) (handle *TxHandle, err error) {
 	defer func() {
 		if er := recover(); er != nil {
 			dot.Logger().Errorln("", zap.Any("failed to prepare to buy , error:", er))
+			handle, err = nil, fmt.Errorf("failed to prepare to buy: %v", er)
 		}
 	}()
 
-	tx, err := c.scryProtocol.CreateTransaction(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), publishId, startVerify)
-	if err == nil {
-		dot.Logger().Debugln("CreateTransaction: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.CreateTransaction(opts, c.appSeqNo(txParams), publishId, startVerify)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("CreateTransaction: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) BuyData(txParams *chainoperations.TransactParams, txId *big.Int) error {
-	tx, err := c.scryProtocol.BuyData(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId)
-	if err == nil {
-		dot.Logger().Debugln("BuyData: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) BuyData(txParams *chainoperations.TransactParams, txId *big.Int) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.BuyData(opts, c.appSeqNo(txParams), txId)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("BuyData: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) CancelTransaction(txParams *chainoperations.TransactParams, txId *big.Int) error {
-	tx, err := c.scryProtocol.CancelTransaction(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId)
-	if err == nil {
-		dot.Logger().Debugln("CancelTransaction tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) CancelTransaction(txParams *chainoperations.TransactParams, txId *big.Int) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.CancelTransaction(opts, c.appSeqNo(txParams), txId)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("CancelTransaction tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) SubmitMetaDataIdEncWithBuyer(txParams *chainoperations.TransactParams, txId *big.Int, encyptedMetaDataId []byte) error {
-	tx, err := c.scryProtocol.SubmitMetaDataIdEncWithBuyer(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId, encyptedMetaDataId)
-	if err == nil {
-		dot.Logger().Debugln("SubmitMetaDataIdEncWithBuyer: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) SubmitMetaDataIdEncWithBuyer(txParams *chainoperations.TransactParams, txId *big.Int, encyptedMetaDataId []byte) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.SubmitMetaDataIdEncWithBuyer(opts, c.appSeqNo(txParams), txId, encyptedMetaDataId)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("SubmitMetaDataIdEncWithBuyer: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) ConfirmDataTruth(txParams *chainoperations.TransactParams, txId *big.Int, truth bool) error {
-	tx, err := c.scryProtocol.ConfirmDataTruth(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId, truth)
-	if err == nil {
-		dot.Logger().Debugln("ConfirmDataTruth: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) ConfirmDataTruth(txParams *chainoperations.TransactParams, txId *big.Int, truth bool) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.ConfirmDataTruth(opts, c.appSeqNo(txParams), txId, truth)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("ConfirmDataTruth: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) ApproveTransfer(txParams *chainoperations.TransactParams, spender common.Address, value *big.Int) error {
-	tx, err := c.scryToken.Approve(chainoperations.BuildTransactOpts(txParams), spender, value)
-	if err == nil {
-		dot.Logger().Debugln("ApproveTransfer: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) ApproveTransfer(txParams *chainoperations.TransactParams, spender common.Address, value *big.Int) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryToken.Approve(opts, spender, value)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("ApproveTransfer: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) Vote(txParams *chainoperations.TransactParams, txId *big.Int, judge bool, comments string) error {
-	tx, err := c.scryProtocol.Vote(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId, judge, comments)
-	if err == nil {
-		dot.Logger().Debugln("Vote: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
-
+func (c *chainWrapperImp) Vote(txParams *chainoperations.TransactParams, txId *big.Int, judge bool, comments string) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.Vote(opts, c.appSeqNo(txParams), txId, judge, comments)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("Vote: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) RegisterAsVerifier(txParams *chainoperations.TransactParams) error {
-	tx, err := c.scryProtocol.RegisterAsVerifier(chainoperations.BuildTransactOpts(txParams), getAppSeqNo())
-	if err == nil {
-		dot.Logger().Debugln("RegisterAsVerifier: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) RegisterAsVerifier(txParams *chainoperations.TransactParams) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.RegisterAsVerifier(opts, c.appSeqNo(txParams))
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("RegisterAsVerifier: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) CreditsToVerifier(txParams *chainoperations.TransactParams, txId *big.Int, index uint8, credit uint8) error {
-	tx, err := c.scryProtocol.CreditsToVerifier(chainoperations.BuildTransactOpts(txParams), getAppSeqNo(), txId, index, credit)
-	if err == nil {
-		dot.Logger().Debugln("CreditsToVerifier: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) CreditsToVerifier(txParams *chainoperations.TransactParams, txId *big.Int, index uint8, credit uint8) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryProtocol.CreditsToVerifier(opts, c.appSeqNo(txParams), txId, index, credit)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("CreditsToVerifier: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
-func (c *chainWrapperImp) TransferTokens(txParams *chainoperations.TransactParams, to common.Address, value *big.Int) error {
-	tx, err := c.scryToken.Transfer(chainoperations.BuildTransactOpts(txParams), to, value)
-	if err == nil {
-		dot.Logger().Debugln("TransferTokens: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
+func (c *chainWrapperImp) TransferTokens(txParams *chainoperations.TransactParams, to common.Address, value *big.Int) (*TxHandle, error) {
+	tx, err := c.transactor.Transact(txParams, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.scryToken.Transfer(opts, to, value)
+	})
+	if err != nil {
+		return nil, err
 	}
+	dot.Logger().Debugln("TransferTokens: tx hash:" + tx.Hash().String(), zap.Binary(" tx data:", tx.Data()))
 
-	return err
+	return c.track(tx), nil
 }
 
 func (c *chainWrapperImp) GetTokenBalance(txParams *chainoperations.TransactParams, owner common.Address) (*big.Int, error) {
@@ -217,14 +267,102 @@ func (c *chainWrapperImp) GetTokenBalance(txParams *chainoperations.TransactPara
 func (c *chainWrapperImp) TransferEth(from common.Address,
 	password string,
 	to common.Address,
-	value *big.Int) (*types.Transaction, error) {
-	return chainoperations.TransferEth(from, password, to, value, c.conn)
+	value *big.Int) (*TxHandle, error) {
+	tx, err := c.transactor.TransferEth(from, password, to, value, c.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.track(tx), nil
+}
+
+// SpeedUp sends the newest transaction of handle again with a higher gas price, Wait of handle follows it.
+func (c *chainWrapperImp) SpeedUp(txParams *chainoperations.TransactParams, handle *TxHandle) (*TxHandle, error) {
+	return c.replace(txParams, handle, false)
+}
+
+// Cancel replaces the newest transaction of handle by an empty transfer, Wait of handle then returns ErrTxCancelled.
+func (c *chainWrapperImp) Cancel(txParams *chainoperations.TransactParams, handle *TxHandle) (*TxHandle, error) {
+	return c.replace(txParams, handle, true)
+}
+
+func (c *chainWrapperImp) replace(txParams *chainoperations.TransactParams, handle *TxHandle, cancel bool) (*TxHandle, error) {
+	latest := handle.Latest()
+	receipt, err := latest.fetchReceipt(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if receipt != nil {
+		return nil, errors.New("transaction already mined: " + latest.Hash().String())
+	}
+
+	var tx *types.Transaction
+	if cancel {
+		tx, err = c.transactor.Cancel(txParams, latest.Transaction(), c.conn)
+	} else {
+		tx, err = c.transactor.SpeedUp(txParams, latest.Transaction(), c.conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dot.Logger().Debugln("replace transaction: " + latest.Hash().String() + " by " + tx.Hash().String())
+
+	next := NewTxHandle(tx, c.txBackend)
+	next.cancel = cancel
+	latest.replace(next)
+
+	return c.keep(next), nil
+}
+
+// TxByHash is the handle of a transaction sent by the wrapper and not resolved yet, nil for another one.
+func (c *chainWrapperImp) TxByHash(hash common.Hash) *TxHandle {
+	c.txsMu.Lock()
+	defer c.txsMu.Unlock()
+	if h := c.txs[hash]; h != nil && !h.Resolved() {
+		return h
+	}
+	return nil
+}
+
+// track returns the handle of a sent tx and keeps it to be found by hash.
+func (c *chainWrapperImp) track(tx *types.Transaction) *TxHandle {
+	return c.keep(NewTxHandle(tx, c.txBackend))
+}
+
+// keep drops the handles resolved by Wait and, past maxTrackedTxs, the oldest ones.
+func (c *chainWrapperImp) keep(handle *TxHandle) *TxHandle {
+	c.txsMu.Lock()
+	defer c.txsMu.Unlock()
+
+	c.txs[handle.Hash()] = handle
+	order := append(c.txOrder, handle.Hash())
+	c.txOrder = c.txOrder[:0]
+	for _, hash := range order {
+		if h := c.txs[hash]; h == nil || h.Resolved() {
+			delete(c.txs, hash)
+			continue
+		}
+		c.txOrder = append(c.txOrder, hash)
+	}
+	for len(c.txOrder) > maxTrackedTxs {
+		delete(c.txs, c.txOrder[0])
+		c.txOrder = c.txOrder[1:]
+	}
+	return handle
 }
 
 func (c *chainWrapperImp) GetEthBalance(owner common.Address) (*big.Int, error) {
 	return chainoperations.GetEthBalance(owner, c.conn)
 }
 
-func getAppSeqNo() string {
-	return settings.GetAppId()
+func (c *chainWrapperImp) AppId() string {
+	return c.appId
+}
+
+// appSeqNo lets every client stamp its own app, the app of the chain wrapper is the default.
+func (c *chainWrapperImp) appSeqNo(txParams *chainoperations.TransactParams) string {
+	if txParams.AppId != "" {
+		return txParams.AppId
+	}
+	return c.appId
 }
@@ -13,11 +13,15 @@ import (
 
 type Client interface {
 	Account() *accounts.Account
+	// AppId is the app the client transacts as and gets events of
+	AppId() string
+	// SetAppId applies to the transactions and subscriptions made afterwards
+	SetAppId(appId string)
 	SubscribeEvent(eventName string, callback chainevents.EventCallback) error
 	UnSubscribeEvent(eventName string) error
 	Authenticate(password string) (bool, error)
-	TransferEthFrom(from common.Address, password string, value *big.Int, ec *ethclient.Client) error
-	TransferTokenFrom(from common.Address, password string, value *big.Int) error
+	TransferEthFrom(from common.Address, password string, value *big.Int) (*TxHandle, error)
+	TransferTokenFrom(from common.Address, password string, value *big.Int) (*TxHandle, error)
 	GetEth(owner common.Address, ec *ethclient.Client) (*big.Int, error)
 	GetScryToken(owner common.Address) (*big.Int, error)
 }
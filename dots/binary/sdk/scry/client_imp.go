@@ -4,6 +4,7 @@
 package scry
 
 import (
+	"errors"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/scryinfo/dot/dot"
@@ -12,21 +13,29 @@ import (
 	"github.com/scryinfo/dp/dots/binary/sdk/util/accounts"
 	"go.uber.org/zap"
 	"math/big"
+	"sync"
 )
 
 type clientImp struct {
 	account      *accounts.Account
 	chainWrapper ChainWrapper `dot:""`
+	engine       *chainevents.EventEngine
+	appId        string
+	subs         map[string]*chainevents.Subscription
+	mu           sync.Mutex
 }
 
-func NewScryClient(publicKey string, chainWrapper ChainWrapper) Client {
+func NewScryClient(publicKey string, chainWrapper ChainWrapper, engine *chainevents.EventEngine) Client {
 	return &clientImp{
 		account:      &accounts.Account{Address: publicKey},
 		chainWrapper: chainWrapper,
+		engine:       engine,
+		appId:        defaultAppId(chainWrapper, engine),
+		subs:         make(map[string]*chainevents.Subscription),
 	}
 }
 
-func CreateScryClient(password string, chainWrapper ChainWrapper) (Client, error) {
+func CreateScryClient(password string, chainWrapper ChainWrapper, engine *chainevents.EventEngine) (Client, error) {
 	account, err := accounts.GetAMInstance().CreateAccount(password)
 	if err != nil {
 		dot.Logger().Errorln("", zap.NamedError("failed to create account, error:", err))
@@ -36,36 +45,93 @@ func CreateScryClient(password string, chainWrapper ChainWrapper) (Client, error
 	return &clientImp{
 		account:      account,
 		chainWrapper: chainWrapper,
+		engine:       engine,
+		appId:        defaultAppId(chainWrapper, engine),
+		subs:         make(map[string]*chainevents.Subscription),
 	}, nil
 }
 
+// defaultAppId lets a client get the events of the app its engine dispatches.
+func defaultAppId(chainWrapper ChainWrapper, engine *chainevents.EventEngine) string {
+	if engine != nil {
+		return engine.AppId()
+	}
+	if chainWrapper != nil {
+		return chainWrapper.AppId()
+	}
+	return ""
+}
+
 func (c *clientImp) Account() *accounts.Account {
 	return c.account
 }
 
+func (c *clientImp) AppId() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.appId
+}
+
+func (c *clientImp) SetAppId(appId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.appId = appId
+}
+
+// SubscribeEvent replaces the callback this client subscribed for the event before.
 func (c *clientImp) SubscribeEvent(eventName string, callback chainevents.EventCallback) error {
-	return chainevents.SubscribeExternal(common.HexToAddress(c.account.Address), eventName, callback)
+	if eventName == "" {
+		return errors.New("couldn't subscribe event because of empty event name")
+	}
+	filter := chainevents.Filter{
+		Names:   []string{eventName},
+		Address: common.HexToAddress(c.account.Address),
+		Apps:    []string{c.AppId()},
+	}
+	sub, err := c.engine.SubscribeFilter(filter, callback)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.subs[eventName]; old != nil {
+		old.Unsubscribe()
+	}
+	c.subs[eventName] = sub
+
+	return nil
 }
 
 func (c *clientImp) UnSubscribeEvent(eventName string) error {
-	return chainevents.UnSubscribeExternal(common.HexToAddress(c.account.Address), eventName)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := c.subs[eventName]
+	if sub == nil {
+		return errors.New("couldn't find corresponding event to unsubscribe:" + eventName)
+	}
+	sub.Unsubscribe()
+	delete(c.subs, eventName)
+
+	return nil
 }
 
 func (c *clientImp) Authenticate(password string) (bool, error) {
 	return accounts.GetAMInstance().AuthAccount(c.account.Address, password)
 }
 
-func (c *clientImp) TransferEthFrom(from common.Address, password string, value *big.Int, ec *ethclient.Client) error {
-	tx, err := chainoperations.TransferEth(from, password, common.HexToAddress(c.account.Address), value, ec)
+// TransferEthFrom sends on the network of the chain wrapper, with the nonces of its connection.
+func (c *clientImp) TransferEthFrom(from common.Address, password string, value *big.Int) (*TxHandle, error) {
+	handle, err := c.chainWrapper.TransferEth(from, password, common.HexToAddress(c.account.Address), value)
 	if err == nil {
-		dot.Logger().Debugln("transferEthFrom: " + tx.Hash().String() + string(tx.Data()))
+		dot.Logger().Debugln("transferEthFrom: " + handle.Hash().String())
 	}
 
-	return err
+	return handle, err
 }
 
-func (c *clientImp) TransferTokenFrom(from common.Address, password string, value *big.Int) error {
-	txParam := &chainoperations.TransactParams{From: from, Password: password, Value: value}
+func (c *clientImp) TransferTokenFrom(from common.Address, password string, value *big.Int) (*TxHandle, error) {
+	txParam := &chainoperations.TransactParams{From: from, Password: password, Value: value, AppId: c.AppId()}
 	return c.chainWrapper.TransferTokens(txParam,
 		common.HexToAddress(c.account.Address),
 		value)
@@ -0,0 +1,25 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package scry
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
+	"testing"
+)
+
+func TestHandlesKeepTheirAppIds(t *testing.T) {
+	shopA := &chainWrapperImp{appId: "shopA", txs: make(map[common.Hash]*TxHandle)}
+	shopB := &chainWrapperImp{appId: "shopB", txs: make(map[common.Hash]*TxHandle)}
+
+	if got := shopA.appSeqNo(&chainoperations.TransactParams{}); got != "shopA" {
+		t.Errorf("want the app of the chain wrapper, got %s", got)
+	}
+	if got := shopB.appSeqNo(&chainoperations.TransactParams{AppId: "brand"}); got != "brand" {
+		t.Errorf("want the app of the params, got %s", got)
+	}
+	if c := NewScryClient("0x01", shopB, nil); c.AppId() != "shopB" {
+		t.Errorf("want the client to default to the app of its chain wrapper, got %s", c.AppId())
+	}
+}
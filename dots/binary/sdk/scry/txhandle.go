@@ -0,0 +1,306 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package scry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTxFailed is returned by Wait when the transaction was mined but reverted
+	ErrTxFailed = errors.New("transaction failed")
+	// ErrTxDropped is returned by Wait when the node did not know the transaction for txDropPolls polls over txDropGrace
+	ErrTxDropped = errors.New("transaction dropped")
+	// ErrTxCancelled is returned by Wait when the cancel replacing the transaction was mined
+	ErrTxCancelled = errors.New("transaction cancelled")
+
+	receiptPollInterval = 2 * time.Second
+	// a new transaction may be unknown for a while, e.g. to another node behind a load balanced endpoint
+	txDropPolls = 5
+	txDropGrace = time.Minute
+)
+
+// TxBackend is the rpc client of the node, the receipts of ethclient have no block.
+type TxBackend interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// TxHandle is a sent transaction, Wait tells whether it was mined.
+// A speed up or cancel links the handle of the replacing transaction to it.
+type TxHandle struct {
+	tx         *types.Transaction
+	backend    TxBackend
+	receipt    *TxReceipt
+	replaces   *TxHandle
+	replacedBy *TxHandle
+	cancel     bool
+	// resolved is set once Wait knows the outcome of the transaction or of its replacements
+	resolved bool
+	// unknownPolls counts the polls in a row the node did not know the transaction, since unknownSince
+	unknownPolls int
+	unknownSince time.Time
+	mu           sync.Mutex
+}
+
+// TxReceipt is the receipt with the block the transaction was mined in.
+type TxReceipt struct {
+	*types.Receipt
+	BlockHash   common.Hash
+	BlockNumber uint64
+}
+
+func NewTxHandle(tx *types.Transaction, backend TxBackend) *TxHandle {
+	return &TxHandle{tx: tx, backend: backend}
+}
+
+func (h *TxHandle) Hash() common.Hash {
+	return h.tx.Hash()
+}
+
+func (h *TxHandle) Transaction() *types.Transaction {
+	return h.tx
+}
+
+// Replaces is the transaction this one was sent to speed up or cancel, nil for the first one.
+func (h *TxHandle) Replaces() *TxHandle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.replaces
+}
+
+func (h *TxHandle) ReplacedBy() *TxHandle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.replacedBy
+}
+
+// Latest is the newest transaction sent with the nonce of h.
+func (h *TxHandle) Latest() *TxHandle {
+	latest := h
+	for next := h.ReplacedBy(); next != nil; next = next.ReplacedBy() {
+		latest = next
+	}
+	return latest
+}
+
+// IsCancel tells whether the transaction was sent to cancel the one it replaces.
+func (h *TxHandle) IsCancel() bool {
+	return h.cancel
+}
+
+func (h *TxHandle) replace(next *TxHandle) {
+	h.mu.Lock()
+	h.replacedBy = next
+	h.mu.Unlock()
+	next.mu.Lock()
+	next.replaces = h
+	next.mu.Unlock()
+}
+
+// resolve marks the transactions sharing the nonce of h, one of them was mined or all were dropped.
+func (h *TxHandle) resolve(chain []*TxHandle) {
+	for _, tx := range chain {
+		tx.mu.Lock()
+		tx.resolved = true
+		tx.mu.Unlock()
+	}
+}
+
+// Resolved tells whether Wait found the outcome of the transaction, it can not be replaced any more.
+func (h *TxHandle) Resolved() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.resolved
+}
+
+// chain is h and the transactions replacing it, oldest first.
+func (h *TxHandle) chain() []*TxHandle {
+	chain := []*TxHandle{h}
+	for next := h.ReplacedBy(); next != nil; next = next.ReplacedBy() {
+		chain = append(chain, next)
+	}
+	return chain
+}
+
+// Wait polls until the transaction is mined and confirmations blocks, its own block included, are on top.
+// A receipt of a reorganized block is dropped and waited for again.
+// The receipt of a reverted transaction is returned with ErrTxFailed.
+// When a replacement was mined instead, its receipt is returned, with ErrTxCancelled for a cancel.
+// A failed rpc call is retried on the next poll until ctx is done, the transaction may still be mined.
+func (h *TxHandle) Wait(ctx context.Context, confirmations uint64) (*TxReceipt, error) {
+	for {
+		chain, dropped := h.chain(), 0
+		for _, tx := range chain {
+			receipt, err := tx.poll(ctx, confirmations)
+			if err == ErrTxDropped {
+				dropped++
+				continue
+			}
+			if receipt == nil {
+				continue
+			}
+			if tx != h && receipt != nil {
+				h.mu.Lock()
+				h.receipt = receipt
+				h.mu.Unlock()
+			}
+			if tx.cancel && err == nil {
+				err = ErrTxCancelled
+			}
+			if receipt != nil {
+				h.resolve(chain)
+			}
+			return receipt, err
+		}
+		if dropped == len(chain) {
+			h.resolve(chain)
+			return nil, ErrTxDropped
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(receiptPollInterval):
+		}
+	}
+}
+
+// poll returns a nil receipt and error while the transaction needs more confirmations.
+func (h *TxHandle) poll(ctx context.Context, confirmations uint64) (*TxReceipt, error) {
+	receipt, err := h.fetchReceipt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		var tx json.RawMessage
+		if err := h.backend.CallContext(ctx, &tx, "eth_getTransactionByHash", h.Hash()); err != nil {
+			return nil, err
+		}
+		if h.unknown(isNull(tx)) {
+			return nil, ErrTxDropped
+		}
+		return nil, nil
+	}
+
+	head, err := h.header(ctx, "latest")
+	if err != nil {
+		return nil, err
+	}
+	if confirmations > 1 && head.Number.Uint64()+1 < receipt.BlockNumber+confirmations {
+		return nil, nil
+	}
+	canonical, err := h.header(ctx, hexutil.EncodeUint64(receipt.BlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if canonical.Hash() != receipt.BlockHash {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	h.receipt = receipt
+	h.mu.Unlock()
+	if receipt.Status == types.ReceiptStatusFailed {
+		return receipt, ErrTxFailed
+	}
+	return receipt, nil
+}
+
+func (h *TxHandle) fetchReceipt(ctx context.Context) (*TxReceipt, error) {
+	var raw json.RawMessage
+	if err := h.backend.CallContext(ctx, &raw, "eth_getTransactionReceipt", h.Hash()); err != nil {
+		return nil, err
+	}
+	if isNull(raw) {
+		return nil, nil
+	}
+	var block struct {
+		BlockHash   *common.Hash    `json:"blockHash"`
+		BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, err
+	}
+	// a pending receipt of some nodes has no block yet
+	if block.BlockHash == nil || block.BlockNumber == nil {
+		return nil, nil
+	}
+	receipt := &TxReceipt{Receipt: new(types.Receipt), BlockHash: *block.BlockHash, BlockNumber: uint64(*block.BlockNumber)}
+	if err := json.Unmarshal(raw, receipt.Receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (h *TxHandle) header(ctx context.Context, number string) (*types.Header, error) {
+	var raw json.RawMessage
+	if err := h.backend.CallContext(ctx, &raw, "eth_getBlockByNumber", number, false); err != nil {
+		return nil, err
+	}
+	if isNull(raw) {
+		return nil, ethereum.NotFound
+	}
+	header := new(types.Header)
+	if err := json.Unmarshal(raw, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// unknown counts the polls the node did not know the transaction, true once it is taken as dropped.
+func (h *TxHandle) unknown(unknown bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !unknown {
+		h.unknownPolls = 0
+		return false
+	}
+	if h.unknownPolls == 0 {
+		h.unknownSince = time.Now()
+	}
+	h.unknownPolls++
+	return h.unknownPolls >= txDropPolls && time.Since(h.unknownSince) >= txDropGrace
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// Receipt is nil until Wait returned it.
+func (h *TxHandle) Receipt() *TxReceipt {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.receipt
+}
+
+// Mined tells whether Wait got the receipt, Succeeded whether the transaction did not revert.
+func (h *TxHandle) Mined() bool {
+	return h.Receipt() != nil
+}
+
+func (h *TxHandle) Succeeded() bool {
+	receipt := h.Receipt()
+	return receipt != nil && receipt.Status == types.ReceiptStatusSuccessful
+}
+
+func (h *TxHandle) GasUsed() uint64 {
+	if receipt := h.Receipt(); receipt != nil {
+		return receipt.GasUsed
+	}
+	return 0
+}
+
+func (h *TxHandle) BlockNumber() uint64 {
+	if receipt := h.Receipt(); receipt != nil {
+		return receipt.BlockNumber
+	}
+	return 0
+}
@@ -0,0 +1,256 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package scry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRPC answers the calls of a TxHandle from an in-memory chain.
+type fakeRPC struct {
+	mu       sync.Mutex
+	headers  []*types.Header
+	receipts map[common.Hash]json.RawMessage
+	pool     map[common.Hash]bool
+	// failures is the number of calls that fail before the node answers again
+	failures int
+}
+
+func newFakeRPC(blocks int) *fakeRPC {
+	fr := &fakeRPC{receipts: make(map[common.Hash]json.RawMessage), pool: make(map[common.Hash]bool)}
+	for i := 0; i < blocks; i++ {
+		fr.addBlock()
+	}
+	return fr
+}
+
+func (fr *fakeRPC) addBlock() *types.Header {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	header := &types.Header{Number: big.NewInt(int64(len(fr.headers))), Difficulty: big.NewInt(1), Extra: []byte{byte(len(fr.headers))}}
+	if len(fr.headers) > 0 {
+		header.ParentHash = fr.headers[len(fr.headers)-1].Hash()
+	}
+	fr.headers = append(fr.headers, header)
+	return header
+}
+
+func (fr *fakeRPC) mine(tx *types.Transaction, status uint64) {
+	header := fr.addBlock()
+	receipt := &types.Receipt{Status: status, TxHash: tx.Hash(), GasUsed: 21000, Logs: []*types.Log{}}
+	raw, _ := json.Marshal(receipt)
+	fields := make(map[string]interface{})
+	json.Unmarshal(raw, &fields)
+	fields["blockHash"] = header.Hash()
+	fields["blockNumber"] = hexutil.Uint64(header.Number.Uint64())
+	raw, _ = json.Marshal(fields)
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.receipts[tx.Hash()] = raw
+}
+
+func (fr *fakeRPC) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.failures != 0 {
+		fr.failures--
+		return errors.New("connection reset")
+	}
+	var raw []byte
+	switch method {
+	case "eth_getTransactionReceipt":
+		raw = fr.receipts[args[0].(common.Hash)]
+	case "eth_getTransactionByHash":
+		if fr.pool[args[0].(common.Hash)] {
+			raw = []byte(`{}`)
+		}
+	case "eth_getBlockByNumber":
+		n := len(fr.headers) - 1
+		if args[0] != "latest" {
+			number, _ := hexutil.DecodeUint64(args[0].(string))
+			n = int(number)
+		}
+		raw, _ = json.Marshal(fr.headers[n])
+	default:
+		return fmt.Errorf("unexpected method %s", method)
+	}
+	if raw == nil {
+		raw = []byte("null")
+	}
+	return json.Unmarshal(raw, result)
+}
+
+func testTx(nonce uint64) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil)
+}
+
+func TestWaitForConfirmations(t *testing.T) {
+	defer func(interval time.Duration) { receiptPollInterval = interval }(receiptPollInterval)
+	receiptPollInterval = time.Millisecond
+
+	fr := newFakeRPC(3)
+	tx := testTx(0)
+	fr.pool[tx.Hash()] = true
+	handle := NewTxHandle(tx, fr)
+
+	done := make(chan *TxReceipt)
+	go func() {
+		receipt, err := handle.Wait(context.Background(), 3)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- receipt
+	}()
+	fr.mine(tx, types.ReceiptStatusSuccessful)
+	fr.addBlock()
+	select {
+	case <-done:
+		t.Fatal("expect Wait to need a third confirmation")
+	case <-time.After(20 * time.Millisecond):
+	}
+	fr.addBlock()
+
+	receipt := <-done
+	if receipt.BlockNumber != 3 || handle.BlockNumber() != 3 || handle.GasUsed() != 21000 || !handle.Succeeded() {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+}
+
+func TestWaitReportsFailedAndDropped(t *testing.T) {
+	defer func(interval, grace time.Duration) { receiptPollInterval, txDropGrace = interval, grace }(receiptPollInterval, txDropGrace)
+	receiptPollInterval, txDropGrace = time.Millisecond, 10*time.Millisecond
+
+	fr := newFakeRPC(1)
+	reverted := testTx(0)
+	fr.mine(reverted, types.ReceiptStatusFailed)
+	if _, err := NewTxHandle(reverted, fr).Wait(context.Background(), 1); err != ErrTxFailed {
+		t.Errorf("expect ErrTxFailed, got %v", err)
+	}
+
+	if _, err := NewTxHandle(testTx(1), fr).Wait(context.Background(), 1); err != ErrTxDropped {
+		t.Errorf("expect ErrTxDropped, got %v", err)
+	}
+}
+
+func TestWaitFollowsReplacements(t *testing.T) {
+	defer func(interval time.Duration) { receiptPollInterval = interval }(receiptPollInterval)
+	receiptPollInterval = time.Millisecond
+
+	fr := newFakeRPC(1)
+	tx, sped := testTx(0), types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(2), nil)
+	fr.pool[tx.Hash()], fr.pool[sped.Hash()] = true, true
+	handle, next := NewTxHandle(tx, fr), NewTxHandle(sped, fr)
+	handle.replace(next)
+	if handle.Latest() != next || next.Replaces() != handle {
+		t.Fatal("expect the speed up linked to the transaction it replaces")
+	}
+
+	done := make(chan *TxReceipt)
+	go func() {
+		receipt, err := handle.Wait(context.Background(), 1)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- receipt
+	}()
+	fr.mu.Lock()
+	delete(fr.pool, tx.Hash())
+	fr.mu.Unlock()
+	fr.mine(sped, types.ReceiptStatusSuccessful)
+
+	if receipt := <-done; receipt.TxHash != sped.Hash() || handle.Receipt() != receipt {
+		t.Errorf("expect the receipt of the speed up, got %+v", receipt)
+	}
+}
+
+func TestWaitReportsCancelled(t *testing.T) {
+	fr := newFakeRPC(1)
+	tx, cancel := testTx(0), types.NewTransaction(0, common.Address{9}, big.NewInt(0), 21000, big.NewInt(2), nil)
+	handle, next := NewTxHandle(tx, fr), NewTxHandle(cancel, fr)
+	next.cancel = true
+	handle.replace(next)
+	fr.mine(cancel, types.ReceiptStatusSuccessful)
+
+	if receipt, err := handle.Wait(context.Background(), 1); err != ErrTxCancelled || receipt.TxHash != cancel.Hash() {
+		t.Errorf("expect ErrTxCancelled with the receipt of the cancel, got %v", err)
+	}
+}
+
+func TestTrackedTxsAreReleased(t *testing.T) {
+	fr := newFakeRPC(1)
+	c := &chainWrapperImp{txs: make(map[common.Hash]*TxHandle), txBackend: fr}
+
+	mined := c.track(testTx(0))
+	fr.mine(mined.Transaction(), types.ReceiptStatusSuccessful)
+	if _, err := mined.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if c.TxByHash(mined.Hash()) != nil {
+		t.Error("expect no handle of a resolved transaction")
+	}
+
+	for nonce := uint64(1); nonce <= maxTrackedTxs+10; nonce++ {
+		c.track(testTx(nonce))
+	}
+	if len(c.txs) != maxTrackedTxs || len(c.txOrder) != maxTrackedTxs {
+		t.Errorf("expect %d handles kept, got %d", maxTrackedTxs, len(c.txs))
+	}
+	if c.TxByHash(testTx(1).Hash()) != nil || c.TxByHash(testTx(maxTrackedTxs+10).Hash()) == nil {
+		t.Error("expect the oldest handles dropped first")
+	}
+}
+
+func TestWaitToleratesUnknownNewTx(t *testing.T) {
+	defer func(interval, grace time.Duration) { receiptPollInterval, txDropGrace = interval, grace }(receiptPollInterval, txDropGrace)
+	receiptPollInterval, txDropGrace = time.Millisecond, time.Second
+
+	fr := newFakeRPC(1)
+	tx := testTx(0)
+	done := make(chan error)
+	go func() {
+		_, err := NewTxHandle(tx, fr).Wait(context.Background(), 1)
+		done <- err
+	}()
+
+	// the node behind the endpoint sees the transaction only after some polls
+	time.Sleep(20 * time.Millisecond)
+	fr.mu.Lock()
+	fr.pool[tx.Hash()] = true
+	fr.mu.Unlock()
+	fr.mine(tx, types.ReceiptStatusSuccessful)
+	if err := <-done; err != nil {
+		t.Errorf("expect the late transaction mined, got %v", err)
+	}
+}
+
+func TestWaitRetriesRPCErrors(t *testing.T) {
+	defer func(interval time.Duration) { receiptPollInterval = interval }(receiptPollInterval)
+	receiptPollInterval = time.Millisecond
+
+	fr := newFakeRPC(1)
+	tx := testTx(0)
+	fr.mine(tx, types.ReceiptStatusSuccessful)
+	fr.failures = 3
+	if _, err := NewTxHandle(tx, fr).Wait(context.Background(), 1); err != nil {
+		t.Errorf("expect the mined transaction after the failed calls, got %v", err)
+	}
+
+	fr.failures = -1
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := NewTxHandle(tx, fr).Wait(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("expect Wait to retry until the deadline, got %v", err)
+	}
+}
@@ -4,17 +4,20 @@
 package sdk
 
 import (
+	"context"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dp/dots/binary/sdk/core"
 	"github.com/scryinfo/dp/dots/binary/sdk/core/chainevents"
+	"github.com/scryinfo/dp/dots/binary/sdk/core/chainoperations"
+	"github.com/scryinfo/dp/dots/binary/sdk/core/ethereum/events"
 	"github.com/scryinfo/dp/dots/binary/sdk/scry"
-	"github.com/scryinfo/dp/dots/binary/sdk/settings"
 )
 
 const (
 	startEngineFailed         = "failed to start engine"
 	initContractWrapperFailed = "failed to initialize contract interface"
+	configureGasFailed        = "failed to configure gas"
 
 	protocolAbi = `[
     {
@@ -593,6 +596,12 @@ const (
 	tokenAbi = `[{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"}],"name":"approve","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transferFrom","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[],"name":"INITIAL_SUPPLY","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_subtractedValue","type":"uint256"}],"name":"decreaseApproval","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_addedValue","type":"uint256"}],"name":"increaseApproval","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"},{"name":"_spender","type":"address"}],"name":"allowance","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"inputs":[],"payable":false,"stateMutability":"nonpayable","type":"constructor"},{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Approval","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
 )
 
+// Handle is returned by Init, one process can hold several of them for different networks or appIds.
+type Handle struct {
+	chainWrapper scry.ChainWrapper
+	engine       *chainevents.EventEngine
+}
+
 func Init(
 	ethNodeAddr string,
 	protocolAddr string,
@@ -600,29 +609,46 @@ func Init(
 	keyServiceAddr string,
 	ipfsNodeAddr string,
 	appId string,
-) (scry.ChainWrapper, error) {
-	settings.SetAppId(appId)
-
+	checkpointFile string,
+	deadLetterFile string,
+	gas chainoperations.GasConfig,
+	chainID uint64,
+) (*Handle, error) {
 	contracts := getContracts(protocolAddr, tokenAddr)
-	conn, err := core.StartEngine(
+	connector, engine, err := core.StartEngine(
 		ethNodeAddr,
 		keyServiceAddr,
 		contracts,
-		ipfsNodeAddr)
+		ipfsNodeAddr,
+		appId,
+		checkpointFile,
+		deadLetterFile,
+		chainID)
 	if err != nil {
 		return nil, errors.New(startEngineFailed)
 	}
+	if err = connector.Transactor().ConfigureGas(gas, connector.Conn()); err != nil {
+		engine.Stop()
+		return nil, errors.Wrap(err, configureGasFailed)
+	}
 
 	//todo
 	chain, err := scry.NewChainWrapper(
 		common.HexToAddress(contracts[0].Address),
 		common.HexToAddress(contracts[1].Address),
-		conn)
+		connector.Conn(),
+		connector.RPC(),
+		connector.Transactor(),
+		appId)
 	if err != nil {
+		engine.Stop()
 		return nil, errors.New(initContractWrapperFailed)
 	}
 
-	return chain, err
+	return &Handle{
+		chainWrapper: chain,
+		engine:       engine,
+	}, nil
 }
 
 func getContracts(
@@ -639,7 +665,7 @@ func getContracts(
 		"TransactionClose",
 		"VerifierDisable",
 	}
-	tokenEvents := []string{"Approval"}
+	tokenEvents := []string{"Approval", "Transfer"}
 
 	contracts := []chainevents.ContractInfo{
 		{
@@ -656,6 +682,50 @@ func getContracts(
 	return contracts
 }
 
-func StartScan(fromBlock uint64) {
-	core.StartScan(fromBlock)
+func (h *Handle) ChainWrapper() scry.ChainWrapper {
+	return h.chainWrapper
+}
+
+func (h *Handle) Engine() *chainevents.EventEngine {
+	return h.engine
+}
+
+func (h *Handle) StartScan(fromBlock uint64) {
+	h.engine.SetFromBlock(fromBlock)
+}
+
+// QueryEvents loads past events of [from, to] without triggering the subscribed callbacks.
+func (h *Handle) QueryEvents(
+	ctx context.Context,
+	from uint64,
+	to uint64,
+	names []string,
+	filter events.EventFilter,
+) ([]events.Event, error) {
+	return h.engine.QueryEvents(ctx, from, to, names, filter)
+}
+
+// AddContract watches another contract, for example a new protocol version next to the old one.
+// Its events from block from are backfilled, nil backfills nothing and 0 from genesis,
+// the backfilled events can arrive after newer ones.
+func (h *Handle) AddContract(ctx context.Context, contract chainevents.ContractInfo, from *uint64) error {
+	return h.engine.AddContract(ctx, contract, from)
+}
+
+func (h *Handle) RemoveContract(address common.Address) error {
+	return h.engine.RemoveContract(address)
+}
+
+// DeadLetters lists the events whose callbacks failed every retry.
+func (h *Handle) DeadLetters() ([]chainevents.DeadLetter, error) {
+	return h.engine.DeadLetters()
+}
+
+// ReplayDeadLetters delivers the dead letters to their callbacks again.
+func (h *Handle) ReplayDeadLetters(ctx context.Context) (int, error) {
+	return h.engine.ReplayDeadLetters(ctx)
+}
+
+func (h *Handle) Stop() {
+	h.engine.Stop()
 }
@@ -2,3 +2,210 @@
 // license that can be found in the license file.
 
 package nonce
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Backend is the part of ethclient.Client the manager reads the chain nonce from.
+type Backend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// Manager hands out the nonces of the local accounts, so transactions sent at the same time
+// do not share a nonce. Every nonce returned by Next must be given back by Done.
+// Nonces belong to one network, so every connection has a manager of its own.
+type Manager struct {
+	backend  Backend
+	accounts map[common.Address]*account
+	mu       sync.Mutex
+}
+
+type account struct {
+	addr   common.Address
+	synced bool
+	next   uint64
+	// released are nonces below next whose transaction was never sent, sorted
+	released []uint64
+	pending  int
+	mu       sync.Mutex
+}
+
+func NewManager(backend Backend) *Manager {
+	return &Manager{backend: backend, accounts: make(map[common.Address]*account)}
+}
+
+func (m *Manager) account(addr common.Address) (*account, Backend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acc, ok := m.accounts[addr]
+	if !ok {
+		acc = &account{addr: addr}
+		m.accounts[addr] = acc
+	}
+	return acc, m.backend
+}
+
+// Next returns the nonce for the next transaction of addr, a nonce released by Done is reused first.
+func (m *Manager) Next(ctx context.Context, addr common.Address) (uint64, error) {
+	acc, backend := m.account(addr)
+	if backend == nil {
+		return 0, errors.New("nonce manager is not initialized")
+	}
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	if !acc.synced {
+		if err := acc.sync(ctx, backend); err != nil {
+			return 0, err
+		}
+	}
+	var nonce uint64
+	if len(acc.released) > 0 {
+		nonce, acc.released = acc.released[0], acc.released[1:]
+	} else {
+		nonce = acc.next
+		acc.next++
+	}
+	acc.pending++
+	return nonce, nil
+}
+
+// Done gives back a nonce from Next with the result of sending its transaction.
+// A sent transaction keeps the nonce, an error of a transaction that was not sent releases it
+// for the next transaction so no gap is left. Any other error, e.g. a timeout after the node
+// may have taken the transaction, makes the next call read the chain again.
+func (m *Manager) Done(addr common.Address, nonce uint64, err error) {
+	acc, _ := m.account(addr)
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	if acc.pending > 0 {
+		acc.pending--
+	}
+	switch {
+	case err == nil:
+	case IsNotSentError(err):
+		acc.release(nonce)
+	default:
+		acc.synced = false
+	}
+}
+
+// Resync reads the nonce of addr from the chain again.
+func (m *Manager) Resync(ctx context.Context, addr common.Address) error {
+	acc, backend := m.account(addr)
+	if backend == nil {
+		return errors.New("nonce manager is not initialized")
+	}
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	return acc.sync(ctx, backend)
+}
+
+// sync moves to the pending nonce of the chain. Nonces still being sent are kept,
+// without them the local nonce may also go back, e.g. after the pool dropped transactions.
+func (acc *account) sync(ctx context.Context, backend Backend) error {
+	chain, err := backend.PendingNonceAt(ctx, acc.addr)
+	if err != nil {
+		return err
+	}
+	if chain > acc.next || acc.pending == 0 {
+		acc.next = chain
+	}
+	var released []uint64
+	for _, n := range acc.released {
+		if n >= chain && n < acc.next {
+			released = append(released, n)
+		}
+	}
+	acc.released = released
+	acc.synced = true
+	return nil
+}
+
+func (acc *account) release(nonce uint64) {
+	if nonce >= acc.next {
+		return
+	}
+	if nonce+1 == acc.next {
+		acc.next = nonce
+		// the released nonces right below go back to next too
+		for len(acc.released) > 0 && acc.released[len(acc.released)-1]+1 == acc.next {
+			acc.next--
+			acc.released = acc.released[:len(acc.released)-1]
+		}
+		return
+	}
+	i := sort.Search(len(acc.released), func(i int) bool { return acc.released[i] >= nonce })
+	if i < len(acc.released) && acc.released[i] == nonce {
+		return
+	}
+	acc.released = append(acc.released, 0)
+	copy(acc.released[i+1:], acc.released[i:])
+	acc.released[i] = nonce
+}
+
+// notSentError is an error raised before the transaction was handed to the node.
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string {
+	return e.err.Error()
+}
+
+// NotSent marks err as raised before the transaction was sent, e.g. by signing.
+func NotSent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &notSentError{err: err}
+}
+
+// refusals are the errors of building a transaction and of the node refusing it before its pool took it.
+var refusals = []string{
+	"failed to estimate gas",
+	"gas required exceeds allowance",
+	"abi: ",
+	"no contract code at given address",
+	"insufficient funds",
+	"intrinsic gas too low",
+	"exceeds block gas limit",
+	"invalid sender",
+	"oversized data",
+	"transaction underpriced",
+	"negative value",
+}
+
+// IsNotSentError tells whether the transaction of err clearly did not reach the pool of the node,
+// so its nonce is free again. A nonce error is not, the chain knows better which nonce is next.
+func IsNotSentError(err error) bool {
+	if err == nil || IsNonceError(err) {
+		return false
+	}
+	if _, ok := err.(*notSentError); ok {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, refusal := range refusals {
+		if strings.Contains(msg, refusal) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNonceError tells whether the node refused a transaction because of its nonce.
+func IsNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "replacement transaction underpriced")
+}
@@ -0,0 +1,117 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package nonce
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"sync"
+	"testing"
+)
+
+var testAccount = common.HexToAddress("0x5aeda56215b167893e80b4fe645ba6d5bab767de")
+
+type fakeBackend struct {
+	mu      sync.Mutex
+	pending uint64
+	reads   int
+}
+
+func (fb *fakeBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	fb.reads++
+	return fb.pending, nil
+}
+
+func next(t *testing.T, m *Manager) uint64 {
+	n, err := m.Next(context.Background(), testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestConcurrentNoncesAreUnique(t *testing.T) {
+	fb := &fakeBackend{pending: 7}
+	m := NewManager(fb)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := next(t, m)
+			m.Done(testAccount, n, nil)
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[n] {
+				t.Errorf("nonce %d handed out twice", n)
+			}
+			seen[n] = true
+		}()
+	}
+	wg.Wait()
+	for n := uint64(7); n < 57; n++ {
+		if !seen[n] {
+			t.Errorf("expect nonce %d used", n)
+		}
+	}
+	if fb.reads != 1 {
+		t.Errorf("expect the chain read once, got %d", fb.reads)
+	}
+}
+
+func TestFailedSendFillsGap(t *testing.T) {
+	m := NewManager(&fakeBackend{pending: 0})
+	a, b, c := next(t, m), next(t, m), next(t, m)
+	m.Done(testAccount, a, nil)
+	m.Done(testAccount, b, errors.New("gas required exceeds allowance"))
+	m.Done(testAccount, c, nil)
+
+	if n := next(t, m); n != b {
+		t.Errorf("expect the released nonce %d again, got %d", b, n)
+	}
+	if n := next(t, m); n != 3 {
+		t.Errorf("expect nonce 3, got %d", n)
+	}
+}
+
+func TestNonceErrorResyncs(t *testing.T) {
+	fb := &fakeBackend{pending: 0}
+	m := NewManager(fb)
+	n := next(t, m)
+	// another wallet sent with the same account meanwhile
+	fb.pending = 5
+	m.Done(testAccount, n, errors.New("nonce too low"))
+
+	if n := next(t, m); n != 5 {
+		t.Errorf("expect nonce 5 from the chain, got %d", n)
+	}
+	if !IsNonceError(errors.New("replacement transaction underpriced")) {
+		t.Error("expect replacement underpriced to be a nonce error")
+	}
+}
+
+func TestAmbiguousErrorKeepsNonce(t *testing.T) {
+	fb := &fakeBackend{pending: 0}
+	m := NewManager(fb)
+	a := next(t, m)
+	// the node took the transaction before the connection broke
+	fb.pending = 1
+	m.Done(testAccount, a, errors.New("context deadline exceeded"))
+
+	if n := next(t, m); n != 1 {
+		t.Errorf("expect nonce 1 from the chain, got %d", n)
+	}
+	if fb.reads != 2 {
+		t.Errorf("expect the chain read again, got %d reads", fb.reads)
+	}
+	if !IsNotSentError(NotSent(errors.New("could not decrypt key"))) {
+		t.Error("expect a signing error marked as not sent")
+	}
+}